@@ -1,115 +1,597 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds all configuration values
 type Config struct {
-	Port     string
-	Env      string
-	MongoDB  MongoDBConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Session  SessionConfig
-	Password PasswordConfig
+	Port string `yaml:"port"`
+	// MetricsPort serves Prometheus metrics, apart from the public API
+	MetricsPort string `yaml:"metrics_port"`
+	Env         string `yaml:"env"`
+	LogLevel    string `yaml:"log_level"`
+	// ShutdownTimeout bounds draining requests and workers and closing
+	// connections after SIGINT or SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// ShutdownDrainDelay is how long the server keeps serving after failing
+	// readiness, so that load balancers stop routing to it first. It counts
+	// against ShutdownTimeout.
+	ShutdownDrainDelay time.Duration       `yaml:"shutdown_drain_delay"`
+	MongoDB            MongoDBConfig       `yaml:"mongodb"`
+	Redis              RedisConfig         `yaml:"redis"`
+	JWT                JWTConfig           `yaml:"jwt"`
+	Session            SessionConfig       `yaml:"session"`
+	UserCache          UserCacheConfig     `yaml:"user_cache"`
+	UserRetention      UserRetentionConfig `yaml:"user_retention"`
+	Password           PasswordConfig      `yaml:"password"`
+	OIDC               OIDCConfig          `yaml:"oidc"`
+	SMTP               SMTPConfig          `yaml:"smtp"`
+	LoginRisk          LoginRiskConfig     `yaml:"login_risk"`
+	Network            NetworkConfig       `yaml:"network"`
+	Tracing            TracingConfig       `yaml:"tracing"`
 }
 
 // MongoDBConfig holds MongoDB configuration
 type MongoDBConfig struct {
-	URI      string
-	Database string
+	URI      string `yaml:"uri" secret:"url"`
+	Database string `yaml:"database"`
+	// MigrateOnStart applies pending migrations when the server starts.
+	// Disable it to run them separately with the migrate command.
+	MigrateOnStart bool `yaml:"migrate_on_start"`
 }
 
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
+	// Mode is "standalone", "sentinel" or "cluster"
+	Mode string `yaml:"mode"`
+	// Addrs lists the server in standalone mode, the Sentinels in sentinel
+	// mode and the seed nodes in cluster mode
+	Addrs []string `yaml:"addrs"`
+	// MasterName names the master set monitored by the Sentinels
+	MasterName       string `yaml:"master_name"`
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password" secret:"true"`
+	SentinelPassword string `yaml:"sentinel_password" secret:"true"`
+	// DB is the database number; Redis Cluster only has database 0
+	DB  int            `yaml:"db"`
+	TLS RedisTLSConfig `yaml:"tls"`
+	// Startup tries to reach Redis ConnectAttempts times, waiting
+	// ConnectBackoff after the first failure and doubling the wait after
+	// each further one, up to ConnectMaxBackoff
+	ConnectAttempts   int           `yaml:"connect_attempts"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff"`
+	ConnectMaxBackoff time.Duration `yaml:"connect_max_backoff"`
+}
+
+// RedisTLSConfig holds the TLS settings of Redis connections
+type RedisTLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile verifies the servers against a private CA instead of the
+	// system roots
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile hold a client certificate for mutual TLS
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+	// InsecureSkipVerify disables certificate verification. Only use it in
+	// development.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
 }
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret    string
-	ExpiresIn time.Duration
+	Secret    string        `yaml:"secret" secret:"true"`
+	ExpiresIn time.Duration `yaml:"expires_in"`
 }
 
 // SessionConfig holds session configuration
 type SessionConfig struct {
-	ExpiresIn time.Duration
+	// Store is where sessions are kept: "redis" or "mongo"
+	Store     string        `yaml:"store"`
+	ExpiresIn time.Duration `yaml:"expires_in"`
+	// CookieEnabled makes login set an HttpOnly session cookie and a CSRF
+	// token cookie for browser clients
+	CookieEnabled  bool   `yaml:"cookie_enabled"`
+	CookieDomain   string `yaml:"cookie_domain"`
+	CookieSecure   bool   `yaml:"cookie_secure"`
+	CookieSameSite string `yaml:"cookie_samesite"`
+	// AllowQueryParam accepts session IDs from the session_id query
+	// parameter. Production refuses it, query strings end up in logs.
+	AllowQueryParam bool `yaml:"allow_query_param"`
+}
+
+// UserCacheConfig holds the configuration of the cache in front of user
+// lookups by ID
+type UserCacheConfig struct {
+	// Store is "memory" for a cache per replica, "redis" for one shared by
+	// all replicas, or "none"
+	Store string `yaml:"store"`
+	// TTL bounds how long a cached user may lag behind changes made through
+	// another replica
+	TTL time.Duration `yaml:"ttl"`
+	// Size is how many users the memory cache holds
+	Size int `yaml:"size"`
+}
+
+// UserRetentionConfig holds how long deleted users are kept before their
+// personal data is purged
+type UserRetentionConfig struct {
+	// Period is how long a deleted user can still be restored
+	Period time.Duration `yaml:"period"`
+	// PurgeInterval is how often each replica purges the users deleted
+	// longer than Period ago
+	PurgeInterval time.Duration `yaml:"purge_interval"`
 }
 
 // PasswordConfig holds password reset configuration
 type PasswordConfig struct {
-	ResetExpiresIn time.Duration
+	ResetExpiresIn time.Duration `yaml:"reset_expires_in"`
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error loading .env file: %w", err)
-	}
+// OIDCConfig holds OpenID Connect federation configuration
+type OIDCConfig struct {
+	StateExpiresIn time.Duration        `yaml:"state_expires_in"`
+	Providers      []OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig holds the settings for a single OpenID Connect provider
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" secret:"true"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// TrustEmail treats the email claim as verified for providers, such as
+	// Azure AD, that do not send an email_verified claim. Such an email only
+	// links an existing account when its domain is listed in JITRoles.
+	TrustEmail bool `yaml:"trust_email"`
+	// LinkAdmins allows linking an identity to an existing admin account by
+	// email. Admins otherwise cannot be taken over through the provider.
+	LinkAdmins bool `yaml:"link_admins"`
+	// JITRoles maps an email domain to the role given to users provisioned
+	// on their first federated login. Domains not listed are never provisioned.
+	JITRoles map[string]string `yaml:"jit_roles"`
+}
+
+// SMTPConfig holds outgoing email configuration. When Host is empty emails
+// are written to the log instead of being sent.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+	From     string `yaml:"from"`
+}
+
+// LoginRiskConfig holds suspicious login detection configuration
+type LoginRiskConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// GeoIPDatabasePath points to an offline MaxMind GeoLite2/GeoIP2 City
+	// database. Impossible travel detection is skipped when it is empty.
+	GeoIPDatabasePath string  `yaml:"geoip_database_path"`
+	HistorySize       int     `yaml:"history_size"`
+	MaxTravelSpeedKmh float64 `yaml:"max_travel_speed_kmh"`
+	Timezone          string  `yaml:"timezone"`
+	// StepUpEnabled requires flagged logins to be confirmed with a code
+	// sent by email before a session is issued
+	StepUpEnabled   bool          `yaml:"step_up_enabled"`
+	StepUpExpiresIn time.Duration `yaml:"step_up_expires_in"`
+}
+
+// NetworkConfig holds client IP and network access policy configuration
+type NetworkConfig struct {
+	// TrustedProxies lists the CIDRs of reverse proxies whose X-Forwarded-For
+	// header is trusted. When empty the client IP is the connection address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Policies holds the default CIDR allow/deny lists per role. Policies
+	// saved through the admin API take precedence.
+	Policies map[string]NetworkPolicyConfig `yaml:"policies"`
+}
+
+// NetworkPolicyConfig holds the CIDR allow and deny lists for a role
+type NetworkPolicyConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
 
-	config := &Config{
-		Port: getEnv("PORT", "8080"),
-		Env:  getEnv("ENV", "development"),
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Exporter is "otlp", "stdout" or "none"
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+	// OTLPEndpoint overrides OTEL_EXPORTER_OTLP_ENDPOINT when set
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// OTLPProtocol is "grpc" or "http"
+	OTLPProtocol string  `yaml:"otlp_protocol"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	return &Config{
+		Port:               "8080",
+		MetricsPort:        "9090",
+		Env:                "development",
+		LogLevel:           "info",
+		ShutdownTimeout:    15 * time.Second,
+		ShutdownDrainDelay: 5 * time.Second,
 		MongoDB: MongoDBConfig{
-			URI:      getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-			Database: getEnv("MONGODB_DATABASE", "future_star_center"),
+			URI:            "mongodb://localhost:27017",
+			Database:       "future_star_center",
+			MigrateOnStart: true,
 		},
 		Redis: RedisConfig{
-			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getEnvAsInt("REDIS_DB", 0),
+			Mode:              "standalone",
+			Addrs:             []string{"localhost:6379"},
+			ConnectAttempts:   5,
+			ConnectBackoff:    time.Second,
+			ConnectMaxBackoff: 15 * time.Second,
 		},
 		JWT: JWTConfig{
-			Secret:    getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			ExpiresIn: getEnvAsDuration("JWT_EXPIRES_IN", "24h"),
+			Secret:    DefaultJWTSecret,
+			ExpiresIn: 24 * time.Hour,
 		},
 		Session: SessionConfig{
-			ExpiresIn: getEnvAsDuration("SESSION_EXPIRES_IN", "7200s"), // 2 hours
+			Store:           "redis",
+			ExpiresIn:       2 * time.Hour,
+			CookieSecure:    true,
+			CookieSameSite:  "strict",
+			AllowQueryParam: false,
+		},
+		UserCache: UserCacheConfig{
+			Store: "memory",
+			TTL:   30 * time.Second,
+			Size:  10000,
+		},
+		UserRetention: UserRetentionConfig{
+			Period:        90 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
 		},
 		Password: PasswordConfig{
-			ResetExpiresIn: getEnvAsDuration("PASSWORD_RESET_EXPIRES_IN", "3600s"), // 1 hour
+			ResetExpiresIn: time.Hour,
+		},
+		OIDC: OIDCConfig{
+			StateExpiresIn: 10 * time.Minute,
+		},
+		SMTP: SMTPConfig{
+			Port: 587,
+			From: "no-reply@futurestarcenter.id",
+		},
+		LoginRisk: LoginRiskConfig{
+			Enabled:           true,
+			HistorySize:       20,
+			MaxTravelSpeedKmh: 1000,
+			Timezone:          "Asia/Jakarta",
+			StepUpExpiresIn:   10 * time.Minute,
 		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			ServiceName:  "future-star-center-api",
+			OTLPProtocol: "grpc",
+			SampleRatio:  1,
+		},
+	}
+}
+
+// Load builds the configuration in layers, each overriding the previous one:
+// defaults, the optional YAML file at path, environment variables (including
+// those from .env) and finally <NAME>_FILE variables naming a file that holds
+// the value, as used for Docker and Kubernetes secrets. The result is
+// validated and all invalid values are reported together. When only the
+// validation fails, the configuration is returned along with the error so
+// that it can still be inspected.
+func Load(path string) (*Config, error) {
+	// Load .env file if it exists
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error loading .env file: %w", err)
+	}
+
+	config := Default()
+	if path != "" {
+		if err := loadFile(path, config); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &envLoader{}
+	l.apply(config)
+
+	if err := errors.Join(append(l.errs, config.Validate())...); err != nil {
+		return config, fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
 	return config, nil
 }
 
+// loadFile decodes a YAML config file over config, rejecting unknown keys so
+// that typos are not silently ignored
+func loadFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	// Email domains are matched in lowercase, as set from the environment
+	for i := range config.OIDC.Providers {
+		config.OIDC.Providers[i].JITRoles = lowerKeys(config.OIDC.Providers[i].JITRoles)
+	}
+	return nil
+}
+
+// lowerKeys returns values with its keys trimmed and in lowercase
+func lowerKeys(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	lowered := make(map[string]string, len(values))
+	for k, v := range values {
+		lowered[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return lowered
+}
+
+// apply overrides config with the values set in the environment
+func (l *envLoader) apply(config *Config) {
+	config.Port = l.getEnv("PORT", config.Port)
+	config.MetricsPort = l.getEnv("METRICS_PORT", config.MetricsPort)
+	config.Env = l.getEnv("ENV", config.Env)
+	config.LogLevel = l.getEnv("LOG_LEVEL", config.LogLevel)
+	config.ShutdownTimeout = l.getEnvAsDuration("SHUTDOWN_TIMEOUT", config.ShutdownTimeout)
+	config.ShutdownDrainDelay = l.getEnvAsDuration("SHUTDOWN_DRAIN_DELAY", config.ShutdownDrainDelay)
+
+	config.MongoDB.URI = l.getEnv("MONGODB_URI", config.MongoDB.URI)
+	config.MongoDB.Database = l.getEnv("MONGODB_DATABASE", config.MongoDB.Database)
+	config.MongoDB.MigrateOnStart = l.getEnvAsBool("MONGODB_MIGRATE_ON_START", config.MongoDB.MigrateOnStart)
+
+	config.Redis.Mode = l.getEnv("REDIS_MODE", config.Redis.Mode)
+	// REDIS_ADDR predates the other modes and is still accepted
+	config.Redis.Addrs = l.getEnvAsList("REDIS_ADDRS", l.getEnvAsList("REDIS_ADDR", config.Redis.Addrs))
+	config.Redis.MasterName = l.getEnv("REDIS_MASTER_NAME", config.Redis.MasterName)
+	config.Redis.Username = l.getEnv("REDIS_USERNAME", config.Redis.Username)
+	config.Redis.Password = l.getEnv("REDIS_PASSWORD", config.Redis.Password)
+	config.Redis.SentinelPassword = l.getEnv("REDIS_SENTINEL_PASSWORD", config.Redis.SentinelPassword)
+	config.Redis.DB = l.getEnvAsInt("REDIS_DB", config.Redis.DB)
+	config.Redis.TLS.Enabled = l.getEnvAsBool("REDIS_TLS_ENABLED", config.Redis.TLS.Enabled)
+	config.Redis.TLS.CAFile = l.getEnv("REDIS_TLS_CA_FILE", config.Redis.TLS.CAFile)
+	config.Redis.TLS.CertFile = l.getEnv("REDIS_TLS_CERT_FILE", config.Redis.TLS.CertFile)
+	config.Redis.TLS.KeyFile = l.getEnv("REDIS_TLS_KEY_FILE", config.Redis.TLS.KeyFile)
+	config.Redis.TLS.ServerName = l.getEnv("REDIS_TLS_SERVER_NAME", config.Redis.TLS.ServerName)
+	config.Redis.TLS.InsecureSkipVerify = l.getEnvAsBool("REDIS_TLS_INSECURE_SKIP_VERIFY", config.Redis.TLS.InsecureSkipVerify)
+	config.Redis.ConnectAttempts = l.getEnvAsInt("REDIS_CONNECT_ATTEMPTS", config.Redis.ConnectAttempts)
+	config.Redis.ConnectBackoff = l.getEnvAsDuration("REDIS_CONNECT_BACKOFF", config.Redis.ConnectBackoff)
+	config.Redis.ConnectMaxBackoff = l.getEnvAsDuration("REDIS_CONNECT_MAX_BACKOFF", config.Redis.ConnectMaxBackoff)
+
+	config.JWT.Secret = l.getEnv("JWT_SECRET", config.JWT.Secret)
+	config.JWT.ExpiresIn = l.getEnvAsDuration("JWT_EXPIRES_IN", config.JWT.ExpiresIn)
+
+	config.Session.Store = l.getEnv("SESSION_STORE", config.Session.Store)
+	config.Session.ExpiresIn = l.getEnvAsDuration("SESSION_EXPIRES_IN", config.Session.ExpiresIn)
+	config.Session.CookieEnabled = l.getEnvAsBool("SESSION_COOKIE_ENABLED", config.Session.CookieEnabled)
+	config.Session.CookieDomain = l.getEnv("SESSION_COOKIE_DOMAIN", config.Session.CookieDomain)
+	config.Session.CookieSecure = l.getEnvAsBool("SESSION_COOKIE_SECURE", config.Session.CookieSecure)
+	config.Session.CookieSameSite = l.getEnv("SESSION_COOKIE_SAMESITE", config.Session.CookieSameSite)
+	config.Session.AllowQueryParam = l.getEnvAsBool("SESSION_ALLOW_QUERY_PARAM", config.Session.AllowQueryParam)
+
+	config.UserCache.Store = l.getEnv("USER_CACHE_STORE", config.UserCache.Store)
+	config.UserCache.TTL = l.getEnvAsDuration("USER_CACHE_TTL", config.UserCache.TTL)
+	config.UserCache.Size = l.getEnvAsInt("USER_CACHE_SIZE", config.UserCache.Size)
+
+	config.UserRetention.Period = l.getEnvAsDuration("USER_RETENTION_PERIOD", config.UserRetention.Period)
+	config.UserRetention.PurgeInterval = l.getEnvAsDuration("USER_PURGE_INTERVAL", config.UserRetention.PurgeInterval)
+
+	config.Password.ResetExpiresIn = l.getEnvAsDuration("PASSWORD_RESET_EXPIRES_IN", config.Password.ResetExpiresIn)
+
+	config.OIDC.StateExpiresIn = l.getEnvAsDuration("OIDC_STATE_EXPIRES_IN", config.OIDC.StateExpiresIn)
+	config.OIDC.Providers = l.loadOIDCProviders(config.OIDC.Providers)
+
+	config.SMTP.Host = l.getEnv("SMTP_HOST", config.SMTP.Host)
+	config.SMTP.Port = l.getEnvAsInt("SMTP_PORT", config.SMTP.Port)
+	config.SMTP.Username = l.getEnv("SMTP_USERNAME", config.SMTP.Username)
+	config.SMTP.Password = l.getEnv("SMTP_PASSWORD", config.SMTP.Password)
+	config.SMTP.From = l.getEnv("SMTP_FROM", config.SMTP.From)
+
+	config.LoginRisk.Enabled = l.getEnvAsBool("LOGIN_RISK_ENABLED", config.LoginRisk.Enabled)
+	config.LoginRisk.GeoIPDatabasePath = l.getEnv("GEOIP_DATABASE_PATH", config.LoginRisk.GeoIPDatabasePath)
+	config.LoginRisk.HistorySize = l.getEnvAsInt("LOGIN_RISK_HISTORY_SIZE", config.LoginRisk.HistorySize)
+	config.LoginRisk.MaxTravelSpeedKmh = l.getEnvAsFloat("LOGIN_RISK_MAX_TRAVEL_SPEED_KMH", config.LoginRisk.MaxTravelSpeedKmh)
+	config.LoginRisk.Timezone = l.getEnv("LOGIN_RISK_TIMEZONE", config.LoginRisk.Timezone)
+	config.LoginRisk.StepUpEnabled = l.getEnvAsBool("LOGIN_STEP_UP_ENABLED", config.LoginRisk.StepUpEnabled)
+	config.LoginRisk.StepUpExpiresIn = l.getEnvAsDuration("LOGIN_STEP_UP_EXPIRES_IN", config.LoginRisk.StepUpExpiresIn)
+
+	config.Network.TrustedProxies = l.getEnvAsList("TRUSTED_PROXIES", config.Network.TrustedProxies)
+	config.Network.Policies = l.loadNetworkPolicies(config.Network.Policies, roles...)
+
+	config.Tracing.Exporter = l.getEnv("TRACING_EXPORTER", config.Tracing.Exporter)
+	config.Tracing.ServiceName = l.getEnv("TRACING_SERVICE_NAME", config.Tracing.ServiceName)
+	config.Tracing.OTLPEndpoint = l.getEnv("TRACING_OTLP_ENDPOINT", config.Tracing.OTLPEndpoint)
+	config.Tracing.OTLPProtocol = l.getEnv("TRACING_OTLP_PROTOCOL", config.Tracing.OTLPProtocol)
+	config.Tracing.OTLPInsecure = l.getEnvAsBool("TRACING_OTLP_INSECURE", config.Tracing.OTLPInsecure)
+	config.Tracing.SampleRatio = l.getEnvAsFloat("TRACING_SAMPLE_RATIO", config.Tracing.SampleRatio)
+}
+
+// loadOIDCProviders adds the providers listed in OIDC_PROVIDERS to those from
+// the config file. Each provider can be configured through OIDC_<NAME>_*
+// variables, e.g. OIDC_GOOGLE_CLIENT_ID.
+func (l *envLoader) loadOIDCProviders(providers []OIDCProviderConfig) []OIDCProviderConfig {
+	for _, name := range l.getEnvAsList("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		if !slices.ContainsFunc(providers, func(p OIDCProviderConfig) bool { return p.Name == name }) {
+			providers = append(providers, OIDCProviderConfig{Name: name})
+		}
+	}
+
+	for i := range providers {
+		provider := &providers[i]
+		prefix := "OIDC_" + strings.ToUpper(provider.Name) + "_"
+		provider.IssuerURL = l.getEnv(prefix+"ISSUER_URL", provider.IssuerURL)
+		provider.ClientID = l.getEnv(prefix+"CLIENT_ID", provider.ClientID)
+		provider.ClientSecret = l.getEnv(prefix+"CLIENT_SECRET", provider.ClientSecret)
+		provider.RedirectURL = l.getEnv(prefix+"REDIRECT_URL", provider.RedirectURL)
+		provider.Scopes = l.getEnvAsList(prefix+"SCOPES", provider.Scopes)
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+		provider.TrustEmail = l.getEnvAsBool(prefix+"TRUST_EMAIL", provider.TrustEmail)
+		provider.LinkAdmins = l.getEnvAsBool(prefix+"LINK_ADMINS", provider.LinkAdmins)
+		provider.JITRoles = l.getEnvAsMap(prefix+"JIT_ROLES", provider.JITRoles)
+	}
+	return providers
+}
+
+// loadNetworkPolicies overrides the policies from the config file with
+// NETWORK_POLICY_<ROLE>_ALLOW and _DENY for each role
+func (l *envLoader) loadNetworkPolicies(policies map[string]NetworkPolicyConfig, roles ...string) map[string]NetworkPolicyConfig {
+	if policies == nil {
+		policies = make(map[string]NetworkPolicyConfig)
+	}
+	for _, role := range roles {
+		prefix := "NETWORK_POLICY_" + strings.ToUpper(role) + "_"
+		policy := policies[role]
+		policy.Allow = l.getEnvAsList(prefix+"ALLOW", policy.Allow)
+		policy.Deny = l.getEnvAsList(prefix+"DENY", policy.Deny)
+		if len(policy.Allow) > 0 || len(policy.Deny) > 0 {
+			policies[role] = policy
+		}
+	}
+	return policies
+}
+
+// envLoader reads environment variables, collecting the ones that cannot be
+// parsed instead of silently falling back to defaults
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) invalid(key, value, kind string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %q is not a valid %s", key, value, kind))
+}
+
+// lookup returns the value of an environment variable. When <key>_FILE is
+// set, the value is read from that file instead, trimming the trailing
+// newline most secret files end with.
+func (l *envLoader) lookup(key string) string {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		return ""
+	}
+	return strings.TrimRight(string(content), "\r\n")
+}
+
 // getEnv gets an environment variable with a fallback value
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
+func (l *envLoader) getEnv(key, fallback string) string {
+	if value := l.lookup(key); value != "" {
 		return value
 	}
 	return fallback
 }
 
 // getEnvAsInt gets an environment variable as integer with a fallback value
-func getEnvAsInt(key string, fallback int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+func (l *envLoader) getEnvAsInt(key string, fallback int) int {
+	if value := l.lookup(key); value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			l.invalid(key, value, "integer")
+			return fallback
 		}
+		return intValue
 	}
 	return fallback
 }
 
-// getEnvAsDuration gets an environment variable as duration with a fallback value
-func getEnvAsDuration(key, fallback string) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+// getEnvAsFloat gets an environment variable as float with a fallback value
+func (l *envLoader) getEnvAsFloat(key string, fallback float64) float64 {
+	if value := l.lookup(key); value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			l.invalid(key, value, "number")
+			return fallback
+		}
+		return floatValue
+	}
+	return fallback
+}
+
+// getEnvAsBool gets an environment variable as boolean with a fallback value
+func (l *envLoader) getEnvAsBool(key string, fallback bool) bool {
+	if value := l.lookup(key); value != "" {
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			l.invalid(key, value, "boolean")
+			return fallback
 		}
+		return boolValue
 	}
-	if duration, err := time.ParseDuration(fallback); err == nil {
+	return fallback
+}
+
+// getEnvAsDuration gets an environment variable as duration with a fallback value
+func (l *envLoader) getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value := l.lookup(key); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			l.invalid(key, value, "duration")
+			return fallback
+		}
 		return duration
 	}
-	return time.Hour // default fallback
+	return fallback
+}
+
+// getEnvAsList gets a comma separated environment variable as a list with a fallback value
+func (l *envLoader) getEnvAsList(key string, fallback []string) []string {
+	var list []string
+	for _, item := range strings.Split(l.lookup(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+// getEnvAsMap gets a comma separated list of key:value pairs as a map with a fallback value
+func (l *envLoader) getEnvAsMap(key string, fallback map[string]string) map[string]string {
+	items := l.getEnvAsList(key, nil)
+	if len(items) == 0 {
+		return fallback
+	}
+
+	values := make(map[string]string)
+	for _, item := range items {
+		k, v, ok := strings.Cut(item, ":")
+		if !ok {
+			l.invalid(key, item, "key:value pair")
+			continue
+		}
+		values[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return values
 }
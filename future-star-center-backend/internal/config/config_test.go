@@ -0,0 +1,165 @@
+package config_test
+
+import (
+	"future-star-center-backend/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	const (
+		yamlSecret = "jwt-secret-from-the-yaml-file"
+		envSecret  = "jwt-secret-from-the-environment"
+		fileSecret = "jwt-secret-from-a-secret-file"
+	)
+
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		// files are written to a temporary directory, and env values
+		// starting with "file:" name one of them
+		files      map[string]string
+		wantPort   string
+		wantSecret string
+		wantErrs   []string
+	}{
+		{
+			name:       "uses the defaults",
+			wantPort:   "8080",
+			wantSecret: config.DefaultJWTSecret,
+		},
+		{
+			name:       "overrides the defaults with the YAML file",
+			yaml:       "port: \"8081\"\njwt:\n  secret: " + yamlSecret + "\n",
+			wantPort:   "8081",
+			wantSecret: yamlSecret,
+		},
+		{
+			name:       "overrides the YAML file with the environment",
+			yaml:       "port: \"8081\"\njwt:\n  secret: " + yamlSecret + "\n",
+			env:        map[string]string{"PORT": "8082", "JWT_SECRET": envSecret},
+			wantPort:   "8082",
+			wantSecret: envSecret,
+		},
+		{
+			name:       "overrides the environment with secret files",
+			yaml:       "jwt:\n  secret: " + yamlSecret + "\n",
+			env:        map[string]string{"JWT_SECRET": envSecret, "JWT_SECRET_FILE": "file:jwt_secret"},
+			files:      map[string]string{"jwt_secret": fileSecret + "\n"},
+			wantPort:   "8080",
+			wantSecret: fileSecret,
+		},
+		{
+			name:     "reports a missing secret file",
+			env:      map[string]string{"JWT_SECRET": envSecret, "JWT_SECRET_FILE": "file:missing"},
+			wantErrs: []string{"JWT_SECRET_FILE"},
+		},
+		{
+			name:     "rejects unknown YAML keys",
+			yaml:     "prot: \"8081\"\n",
+			wantErrs: []string{"field prot not found"},
+		},
+		{
+			name:     "reports unparsable and invalid values together",
+			env:      map[string]string{"REDIS_DB": "zero", "LOG_LEVEL": "verbose"},
+			wantErrs: []string{"REDIS_DB", "LOG_LEVEL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Values from the environment running the tests must not leak in
+			for _, key := range []string{"PORT", "JWT_SECRET", "JWT_SECRET_FILE", "REDIS_DB", "LOG_LEVEL", "ENV"} {
+				t.Setenv(key, "")
+			}
+			for key, value := range tt.env {
+				if name, ok := strings.CutPrefix(value, "file:"); ok {
+					value = filepath.Join(dir, name)
+				}
+				t.Setenv(key, value)
+			}
+
+			path := ""
+			if tt.yaml != "" {
+				path = filepath.Join(dir, "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.yaml), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := config.Load(path)
+			if len(tt.wantErrs) > 0 {
+				if err == nil {
+					t.Fatalf("Load() error = nil, want %v", tt.wantErrs)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error = %v, want it to mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Port != tt.wantPort {
+				t.Errorf("Port = %q, want %q", cfg.Port, tt.wantPort)
+			}
+			if cfg.JWT.Secret != tt.wantSecret {
+				t.Errorf("JWT.Secret = %q, want %q", cfg.JWT.Secret, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestLoadLowercasesJITRoleDomains(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "")
+	t.Setenv("OIDC_GOOGLE_JIT_ROLES", "")
+
+	// Email domains are looked up in lowercase, as the environment sets them
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `oidc:
+  providers:
+    - name: google
+      issuer_url: https://accounts.google.com
+      client_id: client-id
+      redirect_url: https://api.example.com/api/v1/auth/oidc/google/callback
+      jit_roles:
+        Clinic.ORG: staff
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if roles := cfg.OIDC.Providers[0].JITRoles; len(roles) != 1 || roles["clinic.org"] != "staff" {
+		t.Errorf("JITRoles = %v, want map[clinic.org:staff]", roles)
+	}
+}
+
+func TestLoadReturnsInvalidConfig(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	// The configuration is returned with its errors so that it can be printed
+	cfg, err := config.Load("")
+	if err == nil || !strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("Load() error = %v, want it to mention LOG_LEVEL", err)
+	}
+	if cfg == nil || cfg.LogLevel != "verbose" {
+		t.Errorf("Load() config = %+v, want the invalid configuration", cfg)
+	}
+}
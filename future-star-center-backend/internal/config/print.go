@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maskedValue replaces secrets in printed configuration
+const maskedValue = "********"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Print writes the effective configuration as YAML. Fields tagged
+// secret:"true" are masked, and secret:"url" masks the password of a URL.
+func (c *Config) Print(w io.Writer) error {
+	node, err := toNode(reflect.ValueOf(*c), "")
+	if err != nil {
+		return fmt.Errorf("failed to print configuration: %w", err)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(node)
+}
+
+// toNode converts a configuration value to a YAML node, masking secrets and
+// writing durations in their readable form
+func toNode(v reflect.Value, secret string) (*yaml.Node, error) {
+	if v.Kind() == reflect.String && secret != "" {
+		v = reflect.ValueOf(maskSecret(v.String(), secret))
+	}
+
+	switch {
+	case v.Type() == durationType:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: time.Duration(v.Int()).String()}, nil
+	case v.Kind() == reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			value, err := toNode(v.Field(i), field.Tag.Get("secret"))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+		}
+		return node, nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			item, err := toNode(v.Index(i), "")
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		return node, nil
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
+
+func maskSecret(value, secret string) string {
+	if value == "" {
+		return ""
+	}
+	if secret != "url" {
+		return maskedValue
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return maskedValue
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return value
+	}
+
+	// url.UserPassword would percent-encode the mask, so it is spliced in
+	// after the username
+	user := url.User(parsed.User.Username())
+	parsed.User = user
+	prefix := parsed.Scheme + "://" + user.String()
+	return strings.Replace(parsed.String(), prefix+"@", prefix+":"+maskedValue+"@", 1)
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultJWTSecret is the development JWT secret, refused in production
+	DefaultJWTSecret = "your-super-secret-jwt-key"
+	// minSecretLength is the shortest secret accepted in production
+	minSecretLength = 32
+)
+
+// roles lists the user roles that can be referenced from configuration
+var roles = []string{"admin", "therapist", "staff"}
+
+// Validate checks that the configuration is usable, reporting every problem
+// rather than only the first. Production additionally refuses default or
+// weak secrets, insecure session settings and logging emails instead of
+// sending them.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "PORT: %q is not a valid port", c.Port)
+	metricsPort, err := strconv.Atoi(c.MetricsPort)
+	check(err == nil && metricsPort > 0 && metricsPort <= 65535, "METRICS_PORT: %q is not a valid port", c.MetricsPort)
+	check(metricsPort != port, "METRICS_PORT: must differ from PORT")
+	check(slices.Contains([]string{"development", "test", "staging", "production"}, c.Env),
+		"ENV: %q must be development, test, staging or production", c.Env)
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, c.LogLevel),
+		"LOG_LEVEL: %q must be debug, info, warn or error", c.LogLevel)
+
+	_, err = url.Parse(c.MongoDB.URI)
+	check(c.MongoDB.URI != "" && err == nil, "MONGODB_URI: must be a valid connection string")
+	check(c.MongoDB.Database != "", "MONGODB_DATABASE: must not be empty")
+	check(slices.Contains([]string{"standalone", "sentinel", "cluster"}, c.Redis.Mode),
+		"REDIS_MODE: %q must be standalone, sentinel or cluster", c.Redis.Mode)
+	check(len(c.Redis.Addrs) > 0, "REDIS_ADDRS: must not be empty")
+	check(c.Redis.Mode != "standalone" || len(c.Redis.Addrs) <= 1,
+		"REDIS_ADDRS: standalone mode takes a single address")
+	check(c.Redis.Mode != "sentinel" || c.Redis.MasterName != "",
+		"REDIS_MASTER_NAME: must not be empty in sentinel mode")
+	check(c.Redis.DB >= 0, "REDIS_DB: must not be negative")
+	check(c.Redis.Mode != "cluster" || c.Redis.DB == 0, "REDIS_DB: must be 0 in cluster mode")
+	check((c.Redis.TLS.CertFile == "") == (c.Redis.TLS.KeyFile == ""),
+		"REDIS_TLS_CERT_FILE: must be set together with REDIS_TLS_KEY_FILE")
+	check(c.Redis.ConnectAttempts > 0, "REDIS_CONNECT_ATTEMPTS: must be positive")
+	checkPositive(check, "REDIS_CONNECT_BACKOFF", c.Redis.ConnectBackoff)
+	check(c.Redis.ConnectMaxBackoff >= c.Redis.ConnectBackoff,
+		"REDIS_CONNECT_MAX_BACKOFF: must not be shorter than REDIS_CONNECT_BACKOFF")
+
+	check(c.JWT.Secret != "", "JWT_SECRET: must not be empty")
+	checkPositive(check, "SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	check(c.ShutdownDrainDelay >= 0 && c.ShutdownDrainDelay < c.ShutdownTimeout,
+		"SHUTDOWN_DRAIN_DELAY: must not be negative and must be shorter than SHUTDOWN_TIMEOUT")
+	checkPositive(check, "JWT_EXPIRES_IN", c.JWT.ExpiresIn)
+	checkPositive(check, "SESSION_EXPIRES_IN", c.Session.ExpiresIn)
+	checkPositive(check, "PASSWORD_RESET_EXPIRES_IN", c.Password.ResetExpiresIn)
+	checkPositive(check, "OIDC_STATE_EXPIRES_IN", c.OIDC.StateExpiresIn)
+	checkPositive(check, "LOGIN_STEP_UP_EXPIRES_IN", c.LoginRisk.StepUpExpiresIn)
+
+	check(slices.Contains([]string{"redis", "mongo"}, c.Session.Store),
+		"SESSION_STORE: %q must be redis or mongo", c.Session.Store)
+	check(slices.Contains([]string{"strict", "lax", "none"}, c.Session.CookieSameSite),
+		"SESSION_COOKIE_SAMESITE: %q must be strict, lax or none", c.Session.CookieSameSite)
+	check(c.Session.CookieSameSite != "none" || c.Session.CookieSecure,
+		"SESSION_COOKIE_SAMESITE: none requires SESSION_COOKIE_SECURE=true")
+
+	check(slices.Contains([]string{"memory", "redis", "none"}, c.UserCache.Store),
+		"USER_CACHE_STORE: %q must be memory, redis or none", c.UserCache.Store)
+	checkPositive(check, "USER_CACHE_TTL", c.UserCache.TTL)
+	check(c.UserCache.Size > 0, "USER_CACHE_SIZE: must be positive")
+	checkPositive(check, "USER_RETENTION_PERIOD", c.UserRetention.Period)
+	checkPositive(check, "USER_PURGE_INTERVAL", c.UserRetention.PurgeInterval)
+
+	check(c.SMTP.Port > 0 && c.SMTP.Port <= 65535, "SMTP_PORT: %d is not a valid port", c.SMTP.Port)
+
+	check(c.LoginRisk.HistorySize > 0, "LOGIN_RISK_HISTORY_SIZE: must be positive")
+	check(c.LoginRisk.MaxTravelSpeedKmh > 0, "LOGIN_RISK_MAX_TRAVEL_SPEED_KMH: must be positive")
+	_, err = time.LoadLocation(c.LoginRisk.Timezone)
+	check(err == nil, "LOGIN_RISK_TIMEZONE: %q is not a known timezone", c.LoginRisk.Timezone)
+
+	for _, cidr := range c.Network.TrustedProxies {
+		_, _, err := net.ParseCIDR(cidr)
+		check(err == nil, "TRUSTED_PROXIES: %q is not a valid CIDR", cidr)
+	}
+	for role, policy := range c.Network.Policies {
+		for _, cidr := range append(slices.Clone(policy.Allow), policy.Deny...) {
+			_, _, err := net.ParseCIDR(cidr)
+			check(err == nil, "NETWORK_POLICY_%s: %q is not a valid CIDR", strings.ToUpper(role), cidr)
+		}
+	}
+
+	for _, provider := range c.OIDC.Providers {
+		name := strings.ToUpper(provider.Name)
+		_, err := url.ParseRequestURI(provider.IssuerURL)
+		check(err == nil, "OIDC_%s_ISSUER_URL: must be a valid URL", name)
+		check(provider.ClientID != "", "OIDC_%s_CLIENT_ID: must not be empty", name)
+		_, err = url.ParseRequestURI(provider.RedirectURL)
+		check(err == nil, "OIDC_%s_REDIRECT_URL: must be a valid URL", name)
+		for domain, role := range provider.JITRoles {
+			check(slices.Contains(roles, role), "OIDC_%s_JIT_ROLES: %q is not a valid role for %s", name, role, domain)
+		}
+	}
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, c.Tracing.Exporter),
+		"TRACING_EXPORTER: %q must be none, stdout or otlp", c.Tracing.Exporter)
+	check(slices.Contains([]string{"grpc", "http"}, c.Tracing.OTLPProtocol),
+		"TRACING_OTLP_PROTOCOL: %q must be grpc or http", c.Tracing.OTLPProtocol)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"TRACING_SAMPLE_RATIO: %g must be between 0 and 1", c.Tracing.SampleRatio)
+
+	if c.IsProduction() {
+		check(c.JWT.Secret != DefaultJWTSecret, "JWT_SECRET: the default secret is not allowed in production")
+		check(len(c.JWT.Secret) >= minSecretLength,
+			"JWT_SECRET: must be at least %d characters in production", minSecretLength)
+		check(!c.Session.CookieEnabled || c.Session.CookieSecure,
+			"SESSION_COOKIE_SECURE: must be true in production")
+		check(!c.Session.AllowQueryParam,
+			"SESSION_ALLOW_QUERY_PARAM: must be false in production")
+		check(!c.Redis.TLS.InsecureSkipVerify,
+			"REDIS_TLS_INSECURE_SKIP_VERIFY: is not allowed in production")
+		check(c.SMTP.Host != "", "SMTP_HOST: must not be empty in production")
+		for _, provider := range c.OIDC.Providers {
+			check(provider.ClientSecret != "", "OIDC_%s_CLIENT_SECRET: must not be empty in production", strings.ToUpper(provider.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsProduction reports whether the server runs in production
+func (c *Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+func checkPositive(check func(bool, string, ...any), key string, d time.Duration) {
+	check(d > 0, "%s: must be positive", key)
+}
@@ -0,0 +1,180 @@
+package config_test
+
+import (
+	"future-star-center-backend/internal/config"
+	"strings"
+	"testing"
+)
+
+// productionConfig returns a configuration that production accepts
+func productionConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Env = "production"
+	cfg.JWT.Secret = strings.Repeat("s", 32)
+	cfg.SMTP.Host = "smtp.example.com"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func() *config.Config
+		// wantErrs lists the keys reported, one error each
+		wantErrs []string
+	}{
+		{
+			name:   "accepts the defaults",
+			config: config.Default,
+		},
+		{
+			name: "reports every invalid value",
+			config: func() *config.Config {
+				cfg := config.Default()
+				cfg.Port = "http"
+				cfg.LogLevel = "verbose"
+				cfg.Session.Store = "memcached"
+				cfg.UserCache.TTL = 0
+				return cfg
+			},
+			wantErrs: []string{"PORT", "LOG_LEVEL", "SESSION_STORE", "USER_CACHE_TTL"},
+		},
+		{
+			name: "rejects a metrics port shared with the API",
+			config: func() *config.Config {
+				cfg := config.Default()
+				cfg.MetricsPort = cfg.Port
+				return cfg
+			},
+			wantErrs: []string{"METRICS_PORT"},
+		},
+		{
+			name: "rejects a drain delay that uses up the shutdown timeout",
+			config: func() *config.Config {
+				cfg := config.Default()
+				cfg.ShutdownDrainDelay = cfg.ShutdownTimeout
+				return cfg
+			},
+			wantErrs: []string{"SHUTDOWN_DRAIN_DELAY"},
+		},
+		{
+			name:   "accepts a hardened production configuration",
+			config: productionConfig,
+		},
+		{
+			name: "rejects the default JWT secret in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.JWT.Secret = config.DefaultJWTSecret
+				return cfg
+			},
+			// The default secret is also too short
+			wantErrs: []string{"JWT_SECRET: the default", "JWT_SECRET: must be at least"},
+		},
+		{
+			name: "rejects a short JWT secret in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.JWT.Secret = "short"
+				return cfg
+			},
+			wantErrs: []string{"JWT_SECRET"},
+		},
+		{
+			name: "allows a short JWT secret outside production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.Env = "staging"
+				cfg.JWT.Secret = "short"
+				return cfg
+			},
+		},
+		{
+			name: "rejects insecure session cookies in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.Session.CookieEnabled = true
+				cfg.Session.CookieSecure = false
+				cfg.Session.CookieSameSite = "lax"
+				return cfg
+			},
+			wantErrs: []string{"SESSION_COOKIE_SECURE"},
+		},
+		{
+			name: "rejects session IDs in query strings in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.Session.AllowQueryParam = true
+				return cfg
+			},
+			wantErrs: []string{"SESSION_ALLOW_QUERY_PARAM"},
+		},
+		{
+			name: "rejects skipping Redis certificate checks in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.Redis.TLS.InsecureSkipVerify = true
+				return cfg
+			},
+			wantErrs: []string{"REDIS_TLS_INSECURE_SKIP_VERIFY"},
+		},
+		{
+			name: "rejects logging emails in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.SMTP.Host = ""
+				return cfg
+			},
+			wantErrs: []string{"SMTP_HOST"},
+		},
+		{
+			name: "rejects an OIDC provider without a client secret in production",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.OIDC.Providers = []config.OIDCProviderConfig{{
+					Name:        "google",
+					IssuerURL:   "https://accounts.google.com",
+					ClientID:    "client",
+					RedirectURL: "https://api.example.com/api/v1/auth/oidc/google/callback",
+				}}
+				return cfg
+			},
+			wantErrs: []string{"OIDC_GOOGLE_CLIENT_SECRET"},
+		},
+		{
+			name: "reports production checks together with invalid values",
+			config: func() *config.Config {
+				cfg := productionConfig()
+				cfg.SMTP.Host = ""
+				cfg.Redis.Mode = "sharded"
+				return cfg
+			},
+			wantErrs: []string{"REDIS_MODE", "SMTP_HOST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config().Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.wantErrs)
+			}
+
+			// Joined errors are reported one per line
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.wantErrs) {
+				t.Fatalf("Validate() reported %d errors, want %d:\n%v", len(lines), len(tt.wantErrs), err)
+			}
+			for i, want := range tt.wantErrs {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error %d = %q, want it to start with %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix marks a credential as an API key rather than a session ID
+const APIKeyPrefix = "fsc_"
+
+// APIKey represents a machine-to-machine credential managed by admins
+type APIKey struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name"`
+	Prefix     string             `json:"prefix" bson:"prefix"`
+	KeyHash    string             `json:"-" bson:"key_hash"`
+	Scopes     []APIKeyScope      `json:"scopes" bson:"scopes"`
+	CreatedBy  string             `json:"created_by" bson:"created_by"`
+	ExpiresAt  *time.Time         `json:"expires_at" bson:"expires_at"`
+	LastUsedAt *time.Time         `json:"last_used_at" bson:"last_used_at"`
+	RevokedAt  *time.Time         `json:"revoked_at" bson:"revoked_at"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// APIKeyScope represents a permission granted to an API key
+type APIKeyScope string
+
+const (
+	ScopeUsersRead    APIKeyScope = "users:read"
+	ScopeReportsRead  APIKeyScope = "reports:read"
+	ScopeBillingRead  APIKeyScope = "billing:read"
+	ScopeBillingWrite APIKeyScope = "billing:write"
+)
+
+// IsValid checks if the scope is valid
+func (s APIKeyScope) IsValid() bool {
+	switch s {
+	case ScopeUsersRead, ScopeReportsRead, ScopeBillingRead, ScopeBillingWrite:
+		return true
+	}
+	return false
+}
+
+// IsActive checks if the key is neither revoked nor expired at now
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HasScope checks if the key has been granted a scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry records a security relevant event
+type AuditEntry struct {
+	ID        primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Action    string                 `json:"action" bson:"action"`
+	ActorID   string                 `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorRole UserRole               `json:"actor_role,omitempty" bson:"actor_role,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty" bson:"ip_address,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// Audit actions
+const (
+	AuditNetworkPolicyBlocked = "network_policy.blocked"
+	AuditNetworkPolicyUpdated = "network_policy.updated"
+	AuditUserUpdated          = "user.updated"
+	AuditUserDeleted          = "user.deleted"
+	AuditUserRestored         = "user.restored"
+	AuditUsersPurged          = "user.purged"
+)
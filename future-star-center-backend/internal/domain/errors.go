@@ -0,0 +1,124 @@
+package domain
+
+import "errors"
+
+// Error kinds. Every error meant for clients wraps one of them, so callers
+// can check the category with errors.Is and the HTTP layer can pick a status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+	ErrRateLimited  = errors.New("rate limited")
+	// ErrPreconditionFailed and ErrPreconditionRequired report a missing or
+	// stale If-Match header
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+)
+
+// Error is an error whose message is safe to show to clients. Anything that
+// is not an Error, such as a database failure, is reported as an internal
+// error without its details.
+type Error struct {
+	// Kind is one of the error kinds above
+	Kind error
+	// Code is a stable machine readable identifier, e.g. "user_not_found"
+	Code    string
+	Message string
+	// Fields lists the failing fields of a validation error
+	Fields []FieldError
+}
+
+// FieldError describes why a single request field failed validation
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "email" or "allow[0]"
+	Field string `json:"field"`
+	// Rule is the validation rule that failed, e.g. "required"
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// NewError creates a client-safe error of the given kind
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NewValidationError creates a validation error with a specific message
+func NewValidationError(message string) *Error {
+	return NewError(ErrValidation, "validation_error", message)
+}
+
+// NewFieldValidationError creates a validation error listing the failing fields
+func NewFieldValidationError(message string, fields []FieldError) *Error {
+	err := NewValidationError(message)
+	err.Fields = fields
+	return err
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// Request errors
+var (
+	ErrInvalidRequestBody = NewError(ErrValidation, "invalid_request", "invalid request body")
+	ErrInvalidID          = NewError(ErrValidation, "invalid_id", "invalid ID")
+	ErrInvalidRole        = NewError(ErrValidation, "invalid_role", "invalid role")
+)
+
+// Concurrency errors
+var (
+	ErrVersionConflict = NewError(ErrConflict, "version_conflict", "the record was changed by someone else; reload it and try again")
+	ErrVersionMismatch = NewError(ErrPreconditionFailed, "version_mismatch", "the record has changed since the version given in If-Match")
+	ErrVersionRequired = NewError(ErrPreconditionRequired, "if_match_required", "an If-Match header with the record's ETag is required")
+)
+
+// User errors
+var (
+	ErrUserNotFound         = NewError(ErrNotFound, "user_not_found", "user not found")
+	ErrUserExists           = NewError(ErrConflict, "user_exists", "user with this email already exists")
+	ErrIdentityLinked       = NewError(ErrConflict, "identity_linked", "account is already linked to this provider")
+	ErrAccountDeactivated   = NewError(ErrForbidden, "account_deactivated", "account is deactivated")
+	ErrInvalidCredentials   = NewError(ErrUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrInvalidResetToken    = NewError(ErrValidation, "invalid_reset_token", "invalid or expired reset token")
+	ErrInvalidLoginCode     = NewError(ErrUnauthorized, "invalid_verification_code", "invalid or expired verification code")
+	ErrAuthenticationNeeded = NewError(ErrUnauthorized, "authentication_required", "authentication required")
+)
+
+// Session errors
+var (
+	ErrSessionNotFound  = NewError(ErrUnauthorized, "session_invalid", "session not found or expired")
+	ErrCSRFTokenInvalid = NewError(ErrForbidden, "csrf_token_invalid", "missing or invalid CSRF token")
+	ErrInsufficientRole = NewError(ErrForbidden, "insufficient_permissions", "insufficient permissions")
+)
+
+// API key errors
+var (
+	ErrAPIKeyNotFound    = NewError(ErrNotFound, "api_key_not_found", "API key not found")
+	ErrAPIKeyExists      = NewError(ErrConflict, "api_key_exists", "API key with this prefix already exists")
+	ErrInvalidAPIKey     = NewError(ErrUnauthorized, "invalid_api_key", "invalid API key")
+	ErrAPIKeyRevoked     = NewError(ErrUnauthorized, "api_key_revoked", "API key is revoked or expired")
+	ErrInsufficientScope = NewError(ErrForbidden, "insufficient_scope", "API key lacks the required scope")
+)
+
+// OpenID Connect errors
+var (
+	ErrUnknownProvider      = NewError(ErrNotFound, "unknown_provider", "unknown identity provider")
+	ErrProviderDeniedLogin  = NewError(ErrUnauthorized, "provider_denied_login", "the identity provider did not complete the login")
+	ErrInvalidLoginState    = NewError(ErrUnauthorized, "invalid_login_state", "invalid or expired login state")
+	ErrInvalidAuthCode      = NewError(ErrUnauthorized, "invalid_authorization_code", "the identity provider rejected the authorization code")
+	ErrInvalidIDToken       = NewError(ErrUnauthorized, "invalid_id_token", "the identity provider returned an invalid ID token")
+	ErrEmailNotVerified     = NewError(ErrUnauthorized, "email_not_verified", "provider did not return a verified email")
+	ErrNoAccountForIdentity = NewError(ErrForbidden, "no_account", "no account is registered for this identity")
+	ErrIdentityLinkRefused  = NewError(ErrForbidden, "identity_link_refused", "the account with this email cannot be linked to this identity")
+)
+
+// Network policy errors
+var (
+	ErrNetworkNotAllowed = NewError(ErrForbidden, "network_not_allowed", "access from this network is not allowed")
+)
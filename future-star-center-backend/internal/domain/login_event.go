@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginEvent records a successful login and any anomalies detected in it
+type LoginEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    string             `json:"user_id" bson:"user_id"`
+	IPAddress string             `json:"ip_address" bson:"ip_address"`
+	IPRange   string             `json:"ip_range" bson:"ip_range"`
+	UserAgent string             `json:"user_agent" bson:"user_agent"`
+	DeviceID  string             `json:"device_id" bson:"device_id"`
+	Country   string             `json:"country,omitempty" bson:"country,omitempty"`
+	City      string             `json:"city,omitempty" bson:"city,omitempty"`
+	Latitude  *float64           `json:"latitude,omitempty" bson:"latitude,omitempty"`
+	Longitude *float64           `json:"longitude,omitempty" bson:"longitude,omitempty"`
+	Anomalies []LoginAnomaly     `json:"anomalies" bson:"anomalies"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// LoginAnomaly represents a reason a login was considered suspicious
+type LoginAnomaly string
+
+const (
+	AnomalyNewDevice        LoginAnomaly = "new_device"
+	AnomalyNewNetwork       LoginAnomaly = "new_network"
+	AnomalyImpossibleTravel LoginAnomaly = "impossible_travel"
+	AnomalyUnusualHours     LoginAnomaly = "unusual_hours"
+)
+
+// IsSuspicious checks if any anomaly was detected
+func (e *LoginEvent) IsSuspicious() bool {
+	return len(e.Anomalies) > 0
+}
+
+// HasAnomaly checks if a specific anomaly was detected
+func (e *LoginEvent) HasAnomaly(anomaly LoginAnomaly) bool {
+	for _, a := range e.Anomalies {
+		if a == anomaly {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginChallenge represents a pending step-up verification for a flagged login
+type LoginChallenge struct {
+	ID        string      `json:"id"`
+	UserID    string      `json:"user_id"`
+	CodeHash  string      `json:"code_hash"`
+	Attempts  int         `json:"attempts"`
+	Event     *LoginEvent `json:"event"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
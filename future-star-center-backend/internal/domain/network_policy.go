@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"net"
+	"time"
+)
+
+// NetworkPolicy restricts the networks a role can use the API from
+type NetworkPolicy struct {
+	Role      UserRole  `json:"role" bson:"_id"`
+	Allow     []string  `json:"allow" bson:"allow"`
+	Deny      []string  `json:"deny" bson:"deny"`
+	UpdatedBy string    `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Allows checks if an IP address may be used. Deny entries take precedence;
+// an empty allow list allows every address that is not denied.
+func (p *NetworkPolicy) Allows(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	if containsIP(p.Deny, parsed) {
+		return false
+	}
+
+	return len(p.Allow) == 0 || containsIP(p.Allow, parsed)
+}
+
+func containsIP(cidrs []string, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package domain_test
+
+import (
+	"future-star-center-backend/internal/domain"
+	"testing"
+)
+
+func TestNetworkPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy domain.NetworkPolicy
+		ip     string
+		want   bool
+	}{
+		{
+			name: "allows any address without lists",
+			ip:   "203.0.113.7",
+			want: true,
+		},
+		{
+			name:   "allows an address in the allow list",
+			policy: domain.NetworkPolicy{Allow: []string{"10.0.0.0/8", "203.0.113.0/24"}},
+			ip:     "203.0.113.7",
+			want:   true,
+		},
+		{
+			name:   "rejects an address outside the allow list",
+			policy: domain.NetworkPolicy{Allow: []string{"10.0.0.0/8"}},
+			ip:     "203.0.113.7",
+		},
+		{
+			name:   "rejects a denied address",
+			policy: domain.NetworkPolicy{Deny: []string{"203.0.113.0/24"}},
+			ip:     "203.0.113.7",
+		},
+		{
+			name:   "allows an address outside the deny list",
+			policy: domain.NetworkPolicy{Deny: []string{"203.0.113.0/24"}},
+			ip:     "198.51.100.7",
+			want:   true,
+		},
+		{
+			name: "lets deny take precedence over allow",
+			policy: domain.NetworkPolicy{
+				Allow: []string{"203.0.113.0/24"},
+				Deny:  []string{"203.0.113.7/32"},
+			},
+			ip: "203.0.113.7",
+		},
+		{
+			name:   "matches IPv6 ranges",
+			policy: domain.NetworkPolicy{Allow: []string{"2001:db8::/32"}},
+			ip:     "2001:db8::1",
+			want:   true,
+		},
+		{
+			name:   "does not match an IPv4 address against IPv6 ranges",
+			policy: domain.NetworkPolicy{Allow: []string{"2001:db8::/32"}},
+			ip:     "203.0.113.7",
+		},
+		{
+			name:   "ignores invalid CIDR entries",
+			policy: domain.NetworkPolicy{Allow: []string{"not-a-cidr", "203.0.113.0/24"}},
+			ip:     "203.0.113.7",
+			want:   true,
+		},
+		{
+			name: "rejects an address that cannot be parsed",
+			ip:   "not-an-ip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allows(tt.ip); got != tt.want {
+				t.Errorf("Allows(%q) = %t, want %t", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
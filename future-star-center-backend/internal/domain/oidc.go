@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// OIDCAuthState holds the data needed to complete an OpenID Connect login
+// between the authorization redirect and the provider's callback
+type OIDCAuthState struct {
+	State        string    `json:"state"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	Provider     string    `json:"provider"`
+	CreatedAt    time.Time `json:"created_at"`
+}
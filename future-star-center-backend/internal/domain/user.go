@@ -19,8 +19,30 @@ type User struct {
 	LastLogin           *time.Time         `json:"last_login" bson:"last_login"`
 	PasswordResetToken  *string            `json:"-" bson:"password_reset_token"`
 	PasswordResetExpiry *time.Time         `json:"-" bson:"password_reset_expiry"`
+	ExternalIdentities  []ExternalIdentity `json:"-" bson:"external_identities,omitempty"`
 	CreatedAt           time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt           time.Time          `json:"updated_at" bson:"updated_at"`
+	// DeletedAt is set when the user is deleted. Deleted users are hidden
+	// from every lookup and can be restored until they are purged. Live
+	// users store an explicit null, which the unique indexes select on.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at"`
+	// PurgedAt is set once a deleted user's personal data has been
+	// anonymised; the user can no longer be restored
+	PurgedAt *time.Time `json:"-" bson:"purged_at,omitempty"`
+	// Version counts the changes to the fields Update writes and to whether
+	// the user is deleted. Update and Delete only succeed against the version
+	// that was read, so concurrent edits cannot overwrite each other. Logins,
+	// reset tokens and linked identities are written on their own and leave
+	// the version alone.
+	Version int64 `json:"version" bson:"version"`
+}
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider
+type ExternalIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
 }
 
 // UserRole represents user roles in the system
@@ -32,19 +54,19 @@ const (
 	RoleStaff     UserRole = "staff"
 )
 
-// Session represents a user session stored in Redis
+// Session represents a user session stored in Redis or MongoDB
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Email     string    `json:"email"`
-	Role      UserRole  `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"user_id" bson:"user_id"`
+	Email     string    `json:"email" bson:"email"`
+	Role      UserRole  `json:"role" bson:"role"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
 }
 
-// IsValid checks if the session is still valid
-func (s *Session) IsValid() bool {
-	return time.Now().Before(s.ExpiresAt)
+// IsValid checks if the session is still valid at now
+func (s *Session) IsValid(now time.Time) bool {
+	return now.Before(s.ExpiresAt)
 }
 
 // GetFullName returns the full name of the user
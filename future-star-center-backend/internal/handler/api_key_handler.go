@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyHandler handles API key management HTTP requests
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+	validator     *requestValidator
+	logger        *slog.Logger
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService service.APIKeyService, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		validator:     newRequestValidator(),
+		logger:        logger,
+	}
+}
+
+// Create handles API key creation. The key is only returned in this response.
+func (h *APIKeyHandler) Create(c echo.Context) error {
+	var req service.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.ErrInvalidRequestBody
+	}
+
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id").(string)
+	resp, err := h.apiKeyService.Create(c.Request().Context(), req, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, SuccessResponse{
+		Message: "API key created successfully. Store the key now, it will not be shown again",
+		Data:    resp,
+	})
+}
+
+// List handles API key listing
+func (h *APIKeyHandler) List(c echo.Context) error {
+	keys, err := h.apiKeyService.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "API keys retrieved",
+		Data:    keys,
+	})
+}
+
+// Revoke handles API key revocation
+func (h *APIKeyHandler) Revoke(c echo.Context) error {
+	err := h.apiKeyService.Revoke(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "API key revoked",
+	})
+}
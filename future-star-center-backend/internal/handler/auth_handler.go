@@ -1,62 +1,65 @@
 package handler
 
 import (
+	"fmt"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
 	"future-star-center-backend/internal/service"
+	"log/slog"
 	"net/http"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 // AuthHandler handles authentication HTTP requests
 type AuthHandler struct {
-	authService service.AuthService
-	validator   *validator.Validate
+	authService   service.AuthService
+	sessionConfig config.SessionConfig
+	validator     *requestValidator
+	logger        *slog.Logger
 }
 
 // NewAuthHandler creates a new authentication handler
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
+func NewAuthHandler(authService service.AuthService, sessionConfig config.SessionConfig, logger *slog.Logger) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		validator:   validator.New(),
+		authService:   authService,
+		sessionConfig: sessionConfig,
+		validator:     newRequestValidator(),
+		logger:        logger,
 	}
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
-}
-
 // SuccessResponse represents a success response
 type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// SessionResponse describes the current session and its user
+type SessionResponse struct {
+	Valid   bool                  `json:"valid"`
+	Session *domain.Session       `json:"session"`
+	User    *service.UserResponse `json:"user"`
+}
+
 // Register handles user registration
 func (h *AuthHandler) Register(c echo.Context) error {
 	var req service.RegisterRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-		})
+		return domain.ErrInvalidRequestBody
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
 	}
 
 	resp, err := h.authService.Register(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "registration_failed",
-			Message: err.Error(),
-		})
+		return err
+	}
+
+	if err := setSessionCookies(c, h.sessionConfig, resp); err != nil {
+		return fmt.Errorf("failed to set session cookie: %w", err)
 	}
 
 	return c.JSON(http.StatusCreated, SuccessResponse{
@@ -69,27 +72,58 @@ func (h *AuthHandler) Register(c echo.Context) error {
 func (h *AuthHandler) Login(c echo.Context) error {
 	var req service.LoginRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-		})
+		return domain.ErrInvalidRequestBody
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
 	}
 
+	req.IPAddress = c.RealIP()
+	req.UserAgent = c.Request().UserAgent()
+
 	resp, err := h.authService.Login(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "login_failed",
-			Message: err.Error(),
+		return err
+	}
+
+	if resp.StepUpRequired {
+		return c.JSON(http.StatusAccepted, SuccessResponse{
+			Message: "Verification code sent to your email",
+			Data:    resp,
 		})
 	}
 
+	if err := setSessionCookies(c, h.sessionConfig, resp); err != nil {
+		return fmt.Errorf("failed to set session cookie: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Login successful",
+		Data:    resp,
+	})
+}
+
+// VerifyLogin handles step-up verification of a flagged login
+func (h *AuthHandler) VerifyLogin(c echo.Context) error {
+	var req service.VerifyLoginRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.ErrInvalidRequestBody
+	}
+
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
+	}
+
+	resp, err := h.authService.VerifyLogin(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	if err := setSessionCookies(c, h.sessionConfig, resp); err != nil {
+		return fmt.Errorf("failed to set session cookie: %w", err)
+	}
+
 	return c.JSON(http.StatusOK, SuccessResponse{
 		Message: "Login successful",
 		Data:    resp,
@@ -100,20 +134,16 @@ func (h *AuthHandler) Login(c echo.Context) error {
 func (h *AuthHandler) Logout(c echo.Context) error {
 	sessionID := c.Get("session_id")
 	if sessionID == nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_session",
-			Message: "No active session found",
-		})
+		return domain.ErrAuthenticationNeeded
 	}
 
 	err := h.authService.Logout(c.Request().Context(), sessionID.(string))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "logout_failed",
-			Message: err.Error(),
-		})
+		return err
 	}
 
+	clearSessionCookies(c, h.sessionConfig)
+
 	return c.JSON(http.StatusOK, SuccessResponse{
 		Message: "Logout successful",
 	})
@@ -121,30 +151,19 @@ func (h *AuthHandler) Logout(c echo.Context) error {
 
 // RequestPasswordReset handles password reset requests
 func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
-	var req struct {
-		Email string `json:"email" validate:"required,email"`
-	}
+	var req service.RequestPasswordResetRequest
 
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-		})
+		return domain.ErrInvalidRequestBody
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
 	}
 
 	err := h.authService.RequestPasswordReset(c.Request().Context(), req.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "request_failed",
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, SuccessResponse{
@@ -156,25 +175,16 @@ func (h *AuthHandler) RequestPasswordReset(c echo.Context) error {
 func (h *AuthHandler) ResetPassword(c echo.Context) error {
 	var req service.ResetPasswordRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body",
-		})
+		return domain.ErrInvalidRequestBody
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "validation_error",
-			Message: err.Error(),
-		})
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
 	}
 
 	err := h.authService.ResetPassword(c.Request().Context(), req)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "reset_failed",
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, SuccessResponse{
@@ -186,34 +196,25 @@ func (h *AuthHandler) ResetPassword(c echo.Context) error {
 func (h *AuthHandler) GetSession(c echo.Context) error {
 	sessionID := c.Get("session_id")
 	if sessionID == nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_session",
-			Message: "No active session found",
-		})
+		return domain.ErrAuthenticationNeeded
 	}
 
 	session, err := h.authService.GetSession(c.Request().Context(), sessionID.(string))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "session_invalid",
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	user, err := h.authService.ValidateSession(c.Request().Context(), sessionID.(string))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error:   "session_invalid",
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, SuccessResponse{
 		Message: "Session valid",
-		Data: map[string]interface{}{
-			"valid":   true,
-			"session": session,
-			"user":    service.ToUserResponse(user),
+		Data: SessionResponse{
+			Valid:   true,
+			Session: session,
+			User:    service.ToUserResponse(user),
 		},
 	})
 }
@@ -0,0 +1,610 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/handler"
+	"future-star-center-backend/internal/middleware"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const testPassword = "correct-horse-battery"
+
+// testServer serves the auth and user routes over services backed by
+// in-memory repositories and a fake clock
+type testServer struct {
+	echo   *echo.Echo
+	auth   service.AuthService
+	users  repository.UserRepository
+	risk   *fakeLoginRisk
+	mailer *fakeMailer
+	clock  *clock.Fake
+	config *config.Config
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newConfiguredTestServer(t, func(cfg *config.Config) {})
+}
+
+// newConfiguredTestServer is newTestServer with the configuration changed by
+// configure before the handlers are built
+func newConfiguredTestServer(t *testing.T, configure func(cfg *config.Config)) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		risk:   &fakeLoginRisk{},
+		mailer: &fakeMailer{},
+		clock:  clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)),
+		config: config.Default(),
+	}
+	s.config.LoginRisk.StepUpEnabled = true
+	configure(s.config)
+	s.users = repository.NewMemoryUserRepository(s.clock)
+
+	sessions := repository.NewMemorySessionRepository(s.clock)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s.auth = service.NewAuthService(
+		s.users,
+		sessions,
+		repository.NewMemoryLoginChallengeRepository(s.clock),
+		s.risk,
+		s.mailer,
+		s.config,
+		logger,
+		nil,
+		s.clock,
+	)
+
+	h := handler.NewAuthHandler(s.auth, s.config.Session, logger)
+	s.echo = echo.New()
+	s.echo.HTTPErrorHandler = handler.NewHTTPErrorHandler(logger)
+
+	auth := s.echo.Group("/auth")
+	auth.POST("/register", h.Register)
+	auth.POST("/login", h.Login)
+	auth.POST("/login/verify", h.VerifyLogin)
+	auth.POST("/request-password-reset", h.RequestPasswordReset)
+	auth.POST("/reset-password", h.ResetPassword)
+
+	protected := auth.Group("", middleware.AuthMiddleware(s.auth, nil, s.config.Session))
+	protected.POST("/logout", h.Logout)
+	protected.GET("/session", h.GetSession)
+
+	users := handler.NewUserHandler(
+		service.NewUserService(s.users, sessions, nil, discardAudit{}, s.config, logger, s.clock),
+		logger,
+	)
+	s.echo.GET("/admin/users/:id", users.Get)
+	s.echo.PATCH("/admin/users/:id", users.Update)
+	s.echo.DELETE("/admin/users/:id", users.Delete)
+	s.echo.POST("/admin/users/:id/restore", users.Restore)
+	return s
+}
+
+// do sends a request, authenticated by sessionID unless it is empty
+func (s *testServer) do(method, path, body, sessionID string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if sessionID != "" {
+		req.Header.Set("X-Session-ID", sessionID)
+	}
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+// register creates an active user with testPassword and returns its session ID
+func (s *testServer) register(t *testing.T, email string) string {
+	t.Helper()
+
+	resp, err := s.auth.Register(context.Background(), service.RegisterRequest{
+		Email:     email,
+		Password:  testPassword,
+		FirstName: "Ayu",
+		LastName:  "Lestari",
+		Role:      domain.RoleTherapist,
+	})
+	if err != nil {
+		t.Fatalf("Register(%s) error = %v", email, err)
+	}
+	return resp.SessionID
+}
+
+// challenge starts a step-up login and returns the challenge ID and code
+func (s *testServer) challenge(t *testing.T, email string) (string, string) {
+	t.Helper()
+
+	s.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewDevice}
+	resp, err := s.auth.Login(context.Background(), service.LoginRequest{Email: email, Password: testPassword})
+	s.risk.anomalies = nil
+	if err != nil {
+		t.Fatalf("Login(%s) error = %v", email, err)
+	}
+	return resp.ChallengeID, s.mailer.match(t, verificationCode)
+}
+
+var (
+	verificationCode = regexp.MustCompile(`code is (\d{6})`)
+	resetToken       = regexp.MustCompile(`password: (\S+)`)
+)
+
+// fakeMailer records the bodies of the emails it is asked to send
+type fakeMailer struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bodies = append(m.bodies, body)
+	return nil
+}
+
+// match returns the first submatch of pattern in the last email sent
+func (m *fakeMailer) match(t *testing.T, pattern *regexp.Regexp) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.bodies) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := pattern.FindStringSubmatch(m.bodies[len(m.bodies)-1])
+	if match == nil {
+		t.Fatalf("last email does not match %s", pattern)
+	}
+	return match[1]
+}
+
+// fakeLoginRisk flags logins with the configured anomalies
+type fakeLoginRisk struct {
+	anomalies []domain.LoginAnomaly
+}
+
+func (f *fakeLoginRisk) Assess(ctx context.Context, user *domain.User, ipAddress, userAgent string) (*domain.LoginEvent, error) {
+	return &domain.LoginEvent{UserID: user.ID.Hex(), IPAddress: ipAddress, Anomalies: f.anomalies}, nil
+}
+
+func (f *fakeLoginRisk) Record(ctx context.Context, user *domain.User, event *domain.LoginEvent) error {
+	return nil
+}
+
+// discardAudit drops audit entries
+type discardAudit struct{}
+
+func (discardAudit) Create(ctx context.Context, entry *domain.AuditEntry) error {
+	return nil
+}
+
+// request describes the request a test case sends, built once the server's
+// fixtures exist
+type request struct {
+	body      string
+	sessionID string
+	header    http.Header
+}
+
+type routeTest struct {
+	name       string
+	request    func(t *testing.T, s *testServer) request
+	wantStatus int
+	// wantCode is the problem code of an error response
+	wantCode string
+	// wantFields are the failing fields of a validation problem
+	wantFields []string
+}
+
+// runRouteTests sends each case's request to method and path and checks the
+// response status and, for errors, the problem details
+func runRouteTests(t *testing.T, method, path string, tests []routeTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			req := tt.request(t, s)
+
+			rec := s.do(method, path, req.body, req.sessionID, req.header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			if ct := rec.Header().Get(echo.HeaderContentType); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+			var problem handler.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Code != tt.wantCode || problem.Status != tt.wantStatus || problem.Instance != path {
+				t.Errorf("problem = %+v, want code %s", problem, tt.wantCode)
+			}
+
+			var fields []string
+			for _, field := range problem.Errors {
+				fields = append(fields, field.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+// body returns a constant request body
+func body(s string) func(t *testing.T, srv *testServer) request {
+	return func(t *testing.T, srv *testServer) request { return request{body: s} }
+}
+
+func TestAuthHandlerRegister(t *testing.T) {
+	runRouteTests(t, http.MethodPost, "/auth/register", []routeTest{
+		{
+			name:       "creates the user",
+			request:    body(`{"email":"ayu@example.com","password":"correct-horse-battery","first_name":"Ayu","last_name":"Lestari","role":"staff"}`),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "rejects a taken email",
+			request: func(t *testing.T, s *testServer) request {
+				s.register(t, "ayu@example.com")
+				return request{body: `{"email":"ayu@example.com","password":"correct-horse-battery","first_name":"Ayu","last_name":"Lestari","role":"staff"}`}
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   "user_exists",
+		},
+		{
+			name:       "rejects an unknown role",
+			request:    body(`{"email":"ayu@example.com","password":"correct-horse-battery","first_name":"Ayu","last_name":"Lestari","role":"owner"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_role",
+		},
+		{
+			name:       "reports each invalid field",
+			request:    body(`{"email":"not-an-email","password":"short","first_name":"Ayu","last_name":"L","role":"staff"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_error",
+			wantFields: []string{"email", "password", "last_name"},
+		},
+		{
+			name:       "rejects a malformed body",
+			request:    body(`{"email":`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_request",
+		},
+	})
+}
+
+func TestAuthHandlerLogin(t *testing.T) {
+	registered := func(t *testing.T, s *testServer) {
+		s.register(t, "ayu@example.com")
+	}
+
+	runRouteTests(t, http.MethodPost, "/auth/login", []routeTest{
+		{
+			name: "issues a session",
+			request: func(t *testing.T, s *testServer) request {
+				registered(t, s)
+				return request{body: `{"email":"ayu@example.com","password":"correct-horse-battery"}`}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "accepts a suspicious login pending verification",
+			request: func(t *testing.T, s *testServer) request {
+				registered(t, s)
+				s.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewNetwork}
+				return request{body: `{"email":"ayu@example.com","password":"correct-horse-battery"}`}
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "rejects a wrong password",
+			request: func(t *testing.T, s *testServer) request {
+				registered(t, s)
+				return request{body: `{"email":"ayu@example.com","password":"wrong-password"}`}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "invalid_credentials",
+		},
+		{
+			name: "rejects a deactivated account",
+			request: func(t *testing.T, s *testServer) request {
+				registered(t, s)
+				user, _ := s.users.GetByEmail(context.Background(), "ayu@example.com")
+				user.IsActive = false
+				s.users.Update(context.Background(), user)
+				return request{body: `{"email":"ayu@example.com","password":"correct-horse-battery"}`}
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "account_deactivated",
+		},
+		{
+			name:       "requires a password",
+			request:    body(`{"email":"ayu@example.com"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_error",
+			wantFields: []string{"password"},
+		},
+	})
+}
+
+func TestAuthHandlerVerifyLogin(t *testing.T) {
+	runRouteTests(t, http.MethodPost, "/auth/login/verify", []routeTest{
+		{
+			name: "issues a session for the emailed code",
+			request: func(t *testing.T, s *testServer) request {
+				s.register(t, "ayu@example.com")
+				challengeID, code := s.challenge(t, "ayu@example.com")
+				return request{body: `{"challenge_id":"` + challengeID + `","code":"` + code + `"}`}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "rejects an expired challenge",
+			request: func(t *testing.T, s *testServer) request {
+				s.register(t, "ayu@example.com")
+				challengeID, code := s.challenge(t, "ayu@example.com")
+				s.clock.Advance(s.config.LoginRisk.StepUpExpiresIn)
+				return request{body: `{"challenge_id":"` + challengeID + `","code":"` + code + `"}`}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "invalid_verification_code",
+		},
+		{
+			name:       "rejects a code that is not six digits",
+			request:    body(`{"challenge_id":"abc","code":"12ab"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_error",
+			wantFields: []string{"code"},
+		},
+	})
+}
+
+func TestAuthHandlerLogout(t *testing.T) {
+	runRouteTests(t, http.MethodPost, "/auth/logout", []routeTest{
+		{
+			name: "ends the session",
+			request: func(t *testing.T, s *testServer) request {
+				return request{sessionID: s.register(t, "ayu@example.com")}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "requires a session",
+			request:    body(""),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "authentication_required",
+		},
+		{
+			name: "rejects a session that was already ended",
+			request: func(t *testing.T, s *testServer) request {
+				sessionID := s.register(t, "ayu@example.com")
+				s.auth.Logout(context.Background(), sessionID)
+				return request{sessionID: sessionID}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "session_invalid",
+		},
+	})
+}
+
+func TestAuthHandlerRequestPasswordReset(t *testing.T) {
+	runRouteTests(t, http.MethodPost, "/auth/request-password-reset", []routeTest{
+		{
+			name: "accepts a known email",
+			request: func(t *testing.T, s *testServer) request {
+				s.register(t, "ayu@example.com")
+				return request{body: `{"email":"ayu@example.com"}`}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "accepts an unknown email",
+			request:    body(`{"email":"nobody@example.com"}`),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rejects an invalid email",
+			request:    body(`{"email":"nobody"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_error",
+			wantFields: []string{"email"},
+		},
+	})
+}
+
+func TestAuthHandlerResetPassword(t *testing.T) {
+	requestReset := func(t *testing.T, s *testServer) string {
+		s.register(t, "ayu@example.com")
+		if err := s.auth.RequestPasswordReset(context.Background(), "ayu@example.com"); err != nil {
+			t.Fatalf("RequestPasswordReset error = %v", err)
+		}
+		return s.mailer.match(t, resetToken)
+	}
+
+	runRouteTests(t, http.MethodPost, "/auth/reset-password", []routeTest{
+		{
+			name: "sets the new password",
+			request: func(t *testing.T, s *testServer) request {
+				return request{body: `{"token":"` + requestReset(t, s) + `","new_password":"new-correct-horse"}`}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "rejects an expired token",
+			request: func(t *testing.T, s *testServer) request {
+				token := requestReset(t, s)
+				s.clock.Advance(s.config.Password.ResetExpiresIn)
+				return request{body: `{"token":"` + token + `","new_password":"new-correct-horse"}`}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_reset_token",
+		},
+		{
+			name:       "rejects a short password",
+			request:    body(`{"token":"abc","new_password":"short"}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "validation_error",
+			wantFields: []string{"new_password"},
+		},
+	})
+}
+
+func TestAuthHandlerGetSession(t *testing.T) {
+	runRouteTests(t, http.MethodGet, "/auth/session", []routeTest{
+		{
+			name: "describes a live session",
+			request: func(t *testing.T, s *testServer) request {
+				return request{sessionID: s.register(t, "ayu@example.com")}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "accepts a bearer session",
+			request: func(t *testing.T, s *testServer) request {
+				sessionID := s.register(t, "ayu@example.com")
+				return request{header: http.Header{"Authorization": {"Bearer " + sessionID}}}
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "rejects an expired session",
+			request: func(t *testing.T, s *testServer) request {
+				sessionID := s.register(t, "ayu@example.com")
+				s.clock.Advance(s.config.Session.ExpiresIn)
+				return request{sessionID: sessionID}
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "session_invalid",
+		},
+		{
+			name:       "requires a session",
+			request:    body(""),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "authentication_required",
+		},
+	})
+}
+
+func TestAuthHandlerLoginStepUpResponse(t *testing.T) {
+	s := newConfiguredTestServer(t, func(cfg *config.Config) {
+		cfg.Session.CookieEnabled = true
+	})
+	s.register(t, "ayu@example.com")
+	s.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewNetwork}
+
+	rec := s.do(http.MethodPost, "/auth/login", `{"email":"ayu@example.com","password":"correct-horse-battery"}`, "", nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+
+	// A pending login carries the challenge and nothing that authenticates
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Data["step_up_required"] != true || resp.Data["challenge_id"] == "" || resp.Data["challenge_id"] == nil {
+		t.Errorf("data = %v, want step_up_required and a challenge_id", resp.Data)
+	}
+	// expires_at is when the challenge expires
+	if want := float64(s.clock.Now().Add(s.config.LoginRisk.StepUpExpiresIn).Unix()); resp.Data["expires_at"] != want {
+		t.Errorf("expires_at = %v, want %v", resp.Data["expires_at"], want)
+	}
+	for _, field := range []string{"session_id", "token", "user"} {
+		if _, ok := resp.Data[field]; ok {
+			t.Errorf("data = %v, want no %s", resp.Data, field)
+		}
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("cookies = %v, want none before the login is verified", cookies)
+	}
+}
+
+func TestAuthHandlerSetsSessionCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		request func(t *testing.T, s *testServer) string
+	}{
+		{
+			name: "register",
+			path: "/auth/register",
+			request: func(t *testing.T, s *testServer) string {
+				return `{"email":"ayu@example.com","password":"correct-horse-battery","first_name":"Ayu","last_name":"Lestari","role":"staff"}`
+			},
+		},
+		{
+			name: "login",
+			path: "/auth/login",
+			request: func(t *testing.T, s *testServer) string {
+				s.register(t, "ayu@example.com")
+				return `{"email":"ayu@example.com","password":"correct-horse-battery"}`
+			},
+		},
+		{
+			name: "verified login",
+			path: "/auth/login/verify",
+			request: func(t *testing.T, s *testServer) string {
+				s.register(t, "ayu@example.com")
+				challengeID, code := s.challenge(t, "ayu@example.com")
+				return `{"challenge_id":"` + challengeID + `","code":"` + code + `"}`
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newConfiguredTestServer(t, func(cfg *config.Config) {
+				cfg.Session.CookieEnabled = true
+			})
+
+			rec := s.do(http.MethodPost, tt.path, tt.request(t, s), "", nil)
+			if rec.Code >= 300 {
+				t.Fatalf("status = %d, want success: %s", rec.Code, rec.Body)
+			}
+			var resp struct {
+				Data service.AuthResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			cookies := rec.Result().Cookies()
+			session := findCookie(cookies, middleware.SessionCookieName)
+			if session == nil || session.Value != resp.Data.SessionID {
+				t.Errorf("session cookie = %+v, want session %s", session, resp.Data.SessionID)
+			}
+			csrf := findCookie(cookies, middleware.CSRFCookieName)
+			if csrf == nil || csrf.Value != rec.Header().Get(middleware.CSRFHeaderName) {
+				t.Errorf("CSRF cookie = %+v, want the token sent in %s", csrf, middleware.CSRFHeaderName)
+			}
+		})
+	}
+}
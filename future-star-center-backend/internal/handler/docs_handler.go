@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/openapi"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// docsPage renders the OpenAPI document with Redoc. The document is loaded
+// relative to the page, so it works behind a path prefix.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Future Star Center API</title>
+</head>
+<body>
+  <redoc spec-url="openapi.json"></redoc>
+  <script src="https://cdn.redocly.com/redoc/v2.4.0/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// DocsHandler serves the OpenAPI document and its interactive documentation
+type DocsHandler struct {
+	spec *openapi.Document
+}
+
+// NewDocsHandler creates a new documentation handler
+func NewDocsHandler(spec *openapi.Document) *DocsHandler {
+	return &DocsHandler{spec: spec}
+}
+
+// Spec serves the OpenAPI document
+func (h *DocsHandler) Spec(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.spec)
+}
+
+// UI serves the interactive documentation page
+func (h *DocsHandler) UI(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
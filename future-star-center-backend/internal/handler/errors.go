@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response. Code identifies the
+// problem for clients; Type is about:blank as codes are not documented at a
+// URL of their own.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the failing fields of a validation problem
+	Errors []domain.FieldError `json:"errors,omitempty"`
+}
+
+// kindStatuses maps domain error kinds to HTTP status codes
+var kindStatuses = []struct {
+	kind   error
+	status int
+}{
+	{domain.ErrValidation, http.StatusBadRequest},
+	{domain.ErrUnauthorized, http.StatusUnauthorized},
+	{domain.ErrForbidden, http.StatusForbidden},
+	{domain.ErrNotFound, http.StatusNotFound},
+	{domain.ErrConflict, http.StatusConflict},
+	{domain.ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{domain.ErrPreconditionRequired, http.StatusPreconditionRequired},
+	{domain.ErrRateLimited, http.StatusTooManyRequests},
+}
+
+// NewHTTPErrorHandler creates the Echo error handler that turns every error
+// returned by handlers and middleware into a problem details response.
+// Domain errors keep their message; anything else is logged and reported as
+// an internal error so database and driver messages never reach clients.
+func NewHTTPErrorHandler(log *slog.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		problem := toProblem(err)
+		problem.Instance = c.Request().URL.Path
+		problem.RequestID = logger.RequestID(c.Request().Context())
+
+		if problem.Status >= http.StatusInternalServerError {
+			log.ErrorContext(c.Request().Context(), "request failed",
+				"method", c.Request().Method,
+				"route", c.Path(),
+				"error", err,
+			)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, ProblemContentType)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(problem.Status)
+		} else {
+			err = c.JSON(problem.Status, problem)
+		}
+		if err != nil {
+			log.ErrorContext(c.Request().Context(), "failed to write error response", "error", err)
+		}
+	}
+}
+
+func toProblem(err error) Problem {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		status := http.StatusInternalServerError
+		for _, ks := range kindStatuses {
+			if errors.Is(domainErr.Kind, ks.kind) {
+				status = ks.status
+				break
+			}
+		}
+		problem := newProblem(status, domainErr.Code, domainErr.Message)
+		problem.Errors = domainErr.Fields
+		return problem
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code < http.StatusInternalServerError {
+		detail := http.StatusText(httpErr.Code)
+		if message, ok := httpErr.Message.(string); ok {
+			detail = message
+		}
+		return newProblem(httpErr.Code, statusCode(httpErr.Code), detail)
+	}
+
+	return newProblem(http.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+}
+
+func newProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// statusCode derives a problem code from an HTTP status, e.g. "method_not_allowed"
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return fmt.Sprintf("http_%d", status)
+	}
+	return strings.ToLower(strings.ReplaceAll(text, " ", "_"))
+}
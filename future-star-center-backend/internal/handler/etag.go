@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers of conditional requests
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+// setETag tags the response with the version of the record it carries
+func setETag(c echo.Context, version int64) {
+	c.Response().Header().Set(HeaderETag, `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatchVersion returns the record version named by the request's If-Match
+// header. The header must hold a single strong ETag: weak tags never match,
+// and * or a list of tags would let a client skip the version check.
+func ifMatchVersion(c echo.Context) (int64, error) {
+	tag := c.Request().Header.Get(HeaderIfMatch)
+	if tag == "" {
+		return 0, domain.ErrVersionRequired
+	}
+
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, domain.ErrVersionMismatch
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, domain.ErrVersionMismatch
+	}
+	return version, nil
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthCheck pings a dependency, returning an error if it is unavailable
+type HealthCheck func(ctx context.Context) error
+
+// HealthHandler handles liveness and readiness probes
+type HealthHandler struct {
+	checks       map[string]HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+	logger       *slog.Logger
+}
+
+// DependencyStatus reports the result of pinging one dependency. Errors are
+// only logged, since the probe is unauthenticated and they name hosts.
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// LivenessResponse represents the liveness probe response
+type LivenessResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ReadinessResponse represents the readiness probe response
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Timestamp    string                      `json:"timestamp"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// NewHealthHandler creates a new health handler. Each check is given timeout
+// to answer before the dependency is reported as down.
+func NewHealthHandler(checks map[string]HealthCheck, timeout time.Duration, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		checks:  checks,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// ShutDown makes the readiness probe fail so load balancers stop sending
+// traffic while in-flight requests drain
+func (h *HealthHandler) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Livez reports that the process is running. It never checks dependencies,
+// so an outage does not get healthy instances restarted.
+func (h *HealthHandler) Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, LivenessResponse{
+		Status:    "alive",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// Readyz reports whether every dependency answers its ping in time
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	if h.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, ReadinessResponse{
+			Status:    "shutting_down",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+	}
+
+	dependencies := h.runChecks(c.Request().Context())
+
+	resp := ReadinessResponse{
+		Status:       "ready",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Dependencies: dependencies,
+	}
+	status := http.StatusOK
+	for _, dependency := range dependencies {
+		if dependency.Status != "up" {
+			resp.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	return c.JSON(status, resp)
+}
+
+// runChecks pings all dependencies concurrently
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]DependencyStatus {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]DependencyStatus, len(h.checks))
+	)
+
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			result := DependencyStatus{
+				Status:    "up",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = "down"
+				h.logger.WarnContext(ctx, "dependency not ready", "dependency", name, "error", err)
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"future-star-center-backend/internal/handler"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// probe sends a health probe to h, decodes its response into body and
+// returns its status
+func probe(t *testing.T, h echo.HandlerFunc, body any) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	if err := h(c); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+		t.Fatalf("decode probe response: %v", err)
+	}
+	return rec.Code
+}
+
+func up(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+// hanging answers once its context is done, as a dependency that stopped
+// responding does
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newHealthHandler(checks map[string]handler.HealthCheck) *handler.HealthHandler {
+	return handler.NewHealthHandler(checks, 50*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestHealthHandlerLivez(t *testing.T) {
+	h := newHealthHandler(map[string]handler.HealthCheck{"mongodb": down})
+
+	// Liveness ignores dependencies, even while shutting down
+	for _, shuttingDown := range []bool{false, true} {
+		if shuttingDown {
+			h.ShutDown()
+		}
+		var resp handler.LivenessResponse
+		if status := probe(t, h.Livez, &resp); status != http.StatusOK || resp.Status != "alive" {
+			t.Errorf("Livez() = %d %q, want %d alive (shutting down: %t)", status, resp.Status, http.StatusOK, shuttingDown)
+		}
+	}
+}
+
+func TestHealthHandlerReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]handler.HealthCheck
+		wantStatus int
+		want       string
+		// wantDependencies maps each dependency to its reported status
+		wantDependencies map[string]string
+	}{
+		{
+			name:             "ready when every dependency is up",
+			checks:           map[string]handler.HealthCheck{"mongodb": up, "redis": up},
+			wantStatus:       http.StatusOK,
+			want:             "ready",
+			wantDependencies: map[string]string{"mongodb": "up", "redis": "up"},
+		},
+		{
+			name:             "not ready when a dependency fails",
+			checks:           map[string]handler.HealthCheck{"mongodb": up, "redis": down},
+			wantStatus:       http.StatusServiceUnavailable,
+			want:             "not_ready",
+			wantDependencies: map[string]string{"mongodb": "up", "redis": "down"},
+		},
+		{
+			name:             "not ready when a dependency does not answer in time",
+			checks:           map[string]handler.HealthCheck{"mongodb": hanging, "redis": up},
+			wantStatus:       http.StatusServiceUnavailable,
+			want:             "not_ready",
+			wantDependencies: map[string]string{"mongodb": "down", "redis": "up"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealthHandler(tt.checks)
+
+			var resp handler.ReadinessResponse
+			status := probe(t, h.Readyz, &resp)
+			if status != tt.wantStatus || resp.Status != tt.want {
+				t.Errorf("Readyz() = %d %q, want %d %q", status, resp.Status, tt.wantStatus, tt.want)
+			}
+			if len(resp.Dependencies) != len(tt.wantDependencies) {
+				t.Errorf("dependencies = %v, want %v", resp.Dependencies, tt.wantDependencies)
+			}
+			for name, want := range tt.wantDependencies {
+				if got := resp.Dependencies[name].Status; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthHandlerReadyzAfterShutDown(t *testing.T) {
+	checked := false
+	h := newHealthHandler(map[string]handler.HealthCheck{
+		"mongodb": func(ctx context.Context) error {
+			checked = true
+			return nil
+		},
+	})
+	h.ShutDown()
+
+	var resp handler.ReadinessResponse
+	if status := probe(t, h.Readyz, &resp); status != http.StatusServiceUnavailable || resp.Status != "shutting_down" {
+		t.Errorf("Readyz() = %d %q, want %d shutting_down", status, resp.Status, http.StatusServiceUnavailable)
+	}
+	if checked || len(resp.Dependencies) != 0 {
+		t.Errorf("dependencies = %v, want none checked while shutting down", resp.Dependencies)
+	}
+}
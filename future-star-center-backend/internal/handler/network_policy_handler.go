@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NetworkPolicyHandler handles network policy administration HTTP requests
+type NetworkPolicyHandler struct {
+	policyService service.NetworkPolicyService
+	validator     *requestValidator
+	logger        *slog.Logger
+}
+
+// NewNetworkPolicyHandler creates a new network policy handler
+func NewNetworkPolicyHandler(policyService service.NetworkPolicyService, logger *slog.Logger) *NetworkPolicyHandler {
+	return &NetworkPolicyHandler{
+		policyService: policyService,
+		validator:     newRequestValidator(),
+		logger:        logger,
+	}
+}
+
+// List handles network policy listing
+func (h *NetworkPolicyHandler) List(c echo.Context) error {
+	policies, err := h.policyService.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Network policies retrieved",
+		Data:    policies,
+	})
+}
+
+// Update handles replacing a role's network policy
+func (h *NetworkPolicyHandler) Update(c echo.Context) error {
+	var req service.UpdateNetworkPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.ErrInvalidRequestBody
+	}
+
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id").(string)
+	policy, err := h.policyService.Update(c.Request().Context(), domain.UserRole(c.Param("role")), req, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Network policy updated",
+		Data:    policy,
+	})
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"future-star-center-backend/pkg/utils"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OIDCStateCookieName is the cookie binding a pending OIDC login to the
+// browser that started it
+const OIDCStateCookieName = "oidc_state"
+
+// OIDCHandler handles OpenID Connect login HTTP requests
+type OIDCHandler struct {
+	oidcService   service.OIDCService
+	sessionConfig config.SessionConfig
+	oidcConfig    config.OIDCConfig
+	logger        *slog.Logger
+}
+
+// NewOIDCHandler creates a new OpenID Connect login handler
+func NewOIDCHandler(
+	oidcService service.OIDCService,
+	sessionConfig config.SessionConfig,
+	oidcConfig config.OIDCConfig,
+	logger *slog.Logger,
+) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:   oidcService,
+		sessionConfig: sessionConfig,
+		oidcConfig:    oidcConfig,
+		logger:        logger,
+	}
+}
+
+// Providers lists the configured identity providers
+func (h *OIDCHandler) Providers(c echo.Context) error {
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Identity providers retrieved",
+		Data:    h.oidcService.Providers(),
+	})
+}
+
+// Authorize redirects the browser to the identity provider's login page
+func (h *OIDCHandler) Authorize(c echo.Context) error {
+	url, state, err := h.oidcService.AuthorizationURL(c.Request().Context(), c.Param("provider"))
+	if err != nil {
+		h.logger.WarnContext(c.Request().Context(), "OIDC authorization failed", "provider", c.Param("provider"), "error", err)
+		return err
+	}
+
+	// Only this browser can complete the login, so a callback URL sent to
+	// someone else cannot sign them in to the sender's account
+	c.SetCookie(h.newStateCookie(utils.HashToken(state), int(h.oidcConfig.StateExpiresIn.Seconds())))
+	return c.Redirect(http.StatusFound, url)
+}
+
+// Callback completes the login after the identity provider redirects back
+func (h *OIDCHandler) Callback(c echo.Context) error {
+	if providerError := c.QueryParam("error"); providerError != "" {
+		h.logger.WarnContext(c.Request().Context(), "OIDC provider returned an error", "provider", c.Param("provider"), "error", providerError)
+		return domain.ErrProviderDeniedLogin
+	}
+
+	code := c.QueryParam("code")
+	state := c.QueryParam("state")
+	if code == "" || state == "" {
+		return domain.NewValidationError("missing code or state")
+	}
+
+	cookie, err := c.Cookie(OIDCStateCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(utils.HashToken(state))) != 1 {
+		h.logger.WarnContext(c.Request().Context(), "OIDC callback from another browser", "provider", c.Param("provider"))
+		return domain.ErrInvalidLoginState
+	}
+	c.SetCookie(h.newStateCookie("", -1))
+
+	resp, err := h.oidcService.Login(c.Request().Context(), c.Param("provider"), code, state)
+	if err != nil {
+		h.logger.WarnContext(c.Request().Context(), "OIDC login failed", "provider", c.Param("provider"), "error", err)
+		return err
+	}
+
+	if err := setSessionCookies(c, h.sessionConfig, resp); err != nil {
+		return fmt.Errorf("failed to set session cookie: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Login successful",
+		Data:    resp,
+	})
+}
+
+// newStateCookie returns the login state cookie. It is sent on the
+// provider's cross-site redirect back to the callback, so it cannot be
+// SameSite=Strict like the session cookie. A negative maxAge deletes it.
+func (h *OIDCHandler) newStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.sessionConfig.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   h.sessionConfig.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
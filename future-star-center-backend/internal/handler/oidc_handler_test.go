@@ -0,0 +1,128 @@
+package handler_test
+
+import (
+	"context"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/handler"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeOIDC starts logins with a fixed state and records completed ones
+type fakeOIDC struct {
+	logins []string
+}
+
+func (f *fakeOIDC) Providers() []string {
+	return []string{"mock"}
+}
+
+func (f *fakeOIDC) AuthorizationURL(ctx context.Context, provider string) (string, string, error) {
+	return "https://idp.example.com/authorize?state=login-state", "login-state", nil
+}
+
+func (f *fakeOIDC) Login(ctx context.Context, provider, code, state string) (*service.AuthResponse, error) {
+	f.logins = append(f.logins, state)
+	return &service.AuthResponse{User: &service.UserResponse{}, SessionID: "session"}, nil
+}
+
+func TestOIDCHandlerCallbackState(t *testing.T) {
+	tests := []struct {
+		name string
+		// cookie is sent with the callback; nil sends the one Authorize set
+		cookie     *http.Cookie
+		state      string
+		wantStatus int
+	}{
+		{
+			name:       "completes a login started by the same browser",
+			state:      "login-state",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rejects a callback without the state cookie",
+			cookie:     &http.Cookie{Name: "unrelated", Value: "x"},
+			state:      "login-state",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rejects a callback for another browser's login",
+			cookie:     &http.Cookie{Name: handler.OIDCStateCookieName, Value: "another-login"},
+			state:      "login-state",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rejects a state that is not the browser's",
+			state:      "attacker-state",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			oidc := &fakeOIDC{}
+			cfg := config.Default()
+			cfg.Session.CookieSecure = true
+			h := handler.NewOIDCHandler(oidc, cfg.Session, cfg.OIDC, logger)
+
+			e := echo.New()
+			e.HTTPErrorHandler = handler.NewHTTPErrorHandler(logger)
+			e.GET("/auth/oidc/:provider/authorize", h.Authorize)
+			e.GET("/auth/oidc/:provider/callback", h.Callback)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/authorize", nil))
+			if rec.Code != http.StatusFound {
+				t.Fatalf("authorize status = %d, want %d", rec.Code, http.StatusFound)
+			}
+			stateCookie := findCookie(rec.Result().Cookies(), handler.OIDCStateCookieName)
+			if stateCookie == nil {
+				t.Fatal("authorize set no state cookie")
+			}
+			if !stateCookie.HttpOnly || !stateCookie.Secure || stateCookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("state cookie = %+v, want HttpOnly, Secure and SameSite=Lax", stateCookie)
+			}
+			if stateCookie.Value == "login-state" {
+				t.Error("state cookie holds the state itself, want its hash")
+			}
+
+			cookie := tt.cookie
+			if cookie == nil {
+				cookie = stateCookie
+			}
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?code=code&state="+tt.state, nil)
+			req.AddCookie(cookie)
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("callback status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if completed := len(oidc.logins) == 1; completed != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("login completed = %t, want %t", completed, tt.wantStatus == http.StatusOK)
+			}
+			if tt.wantStatus == http.StatusOK {
+				cleared := findCookie(rec.Result().Cookies(), handler.OIDCStateCookieName)
+				if cleared == nil || cleared.MaxAge >= 0 {
+					t.Errorf("state cookie after the callback = %+v, want it deleted", cleared)
+				}
+			}
+		})
+	}
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/openapi"
+	"future-star-center-backend/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIVersion is the version reported in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// route documents an API route. Responses of JSON API routes are wrapped in
+// SuccessResponse with data of the response type; response is nil when the
+// route only returns a message.
+type route struct {
+	method   string
+	path     string
+	id       string
+	summary  string
+	tag      string
+	request  interface{}
+	response interface{}
+	status   int
+	// accepted marks routes that may also answer 202 with the same body
+	accepted  bool
+	errors    []int
+	protected bool
+	// raw replaces the SuccessResponse envelope for routes that answer with
+	// another body, such as probes and redirects
+	raw *openapi.Response
+	// query lists the query parameters
+	query []openapi.Parameter
+	// versioned marks routes of records guarded by a version: responses
+	// other than deletes carry it as an ETag, and writes need it in an
+	// If-Match header
+	versioned bool
+}
+
+// operationRoutes lists the routes served outside the versioned API
+func operationRoutes(doc *openapi.Document) []route {
+	jsonBody := func(v interface{}, description string) *openapi.Response {
+		return &openapi.Response{
+			Description: description,
+			Content:     map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: doc.SchemaFor(v)}},
+		}
+	}
+	readiness := jsonBody(ReadinessResponse{}, "Every dependency is reachable")
+
+	return []route{
+		// Operations
+		{method: http.MethodGet, path: "/livez", id: "livez", tag: "operations",
+			summary: "Liveness probe", raw: jsonBody(LivenessResponse{}, "The process is running")},
+		{method: http.MethodGet, path: "/readyz", id: "readyz", tag: "operations",
+			summary: "Readiness probe, checking MongoDB and Redis", raw: readiness, errors: []int{http.StatusServiceUnavailable}},
+		{method: http.MethodGet, path: "/health", id: "health", tag: "operations",
+			summary: "Alias of the readiness probe", raw: readiness, errors: []int{http.StatusServiceUnavailable}},
+		{method: http.MethodGet, path: "/api/openapi.json", id: "getOpenAPI", tag: "docs",
+			summary: "This OpenAPI document", raw: &openapi.Response{
+				Description: "OpenAPI 3.1 document",
+				Content:     map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: &openapi.Schema{Type: "object"}}},
+			}},
+		{method: http.MethodGet, path: "/api/docs", id: "getDocs", tag: "docs",
+			summary: "Interactive API documentation", raw: &openapi.Response{
+				Description: "HTML page rendering this document",
+				Content:     map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}},
+			}},
+	}
+}
+
+// apiRoutes lists the routes of version 1 of the API, relative to the prefix
+// the version is mounted at. It must be kept in step with the routes
+// registered in main, which a test checks.
+func apiRoutes(doc *openapi.Document) []route {
+	return []route{
+		// Authentication
+		{method: http.MethodPost, path: "/auth/register", id: "register", tag: "auth",
+			summary: "Register a user", request: service.RegisterRequest{}, response: service.AuthResponse{},
+			status: http.StatusCreated, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+		{method: http.MethodPost, path: "/auth/login", id: "login", tag: "auth",
+			summary: "Log in with email and password. Answers 202 with a challenge when the login must be verified",
+			request: service.LoginRequest{}, response: service.AuthResponse{}, accepted: true,
+			errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden}},
+		{method: http.MethodPost, path: "/auth/login/verify", id: "verifyLogin", tag: "auth",
+			summary: "Complete a flagged login with the emailed code", request: service.VerifyLoginRequest{},
+			response: service.AuthResponse{}, errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+		{method: http.MethodPost, path: "/auth/request-password-reset", id: "requestPasswordReset", tag: "auth",
+			summary: "Email a password reset link", request: service.RequestPasswordResetRequest{},
+			errors: []int{http.StatusBadRequest}},
+		{method: http.MethodPost, path: "/auth/reset-password", id: "resetPassword", tag: "auth",
+			summary: "Set a new password with a reset token", request: service.ResetPasswordRequest{},
+			errors: []int{http.StatusBadRequest}},
+		{method: http.MethodPost, path: "/auth/logout", id: "logout", tag: "auth",
+			summary: "End the current session", protected: true},
+		{method: http.MethodGet, path: "/auth/session", id: "getSession", tag: "auth",
+			summary: "Describe the current session", response: SessionResponse{}, protected: true},
+
+		// OpenID Connect
+		{method: http.MethodGet, path: "/auth/oidc/providers", id: "listIdentityProviders", tag: "oidc",
+			summary: "List the configured identity providers", response: []string{}},
+		{method: http.MethodGet, path: "/auth/oidc/{provider}/authorize", id: "authorizeIdentityProvider", tag: "oidc",
+			summary: "Redirect to the identity provider's login page", errors: []int{http.StatusNotFound},
+			raw: &openapi.Response{Description: "Redirect to the identity provider"}, status: http.StatusFound},
+		{method: http.MethodGet, path: "/auth/oidc/{provider}/callback", id: "identityProviderCallback", tag: "oidc",
+			summary: "Complete the login after the identity provider redirects back", response: service.AuthResponse{},
+			errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+			query: []openapi.Parameter{
+				queryParameter("code", "Authorization code"),
+				queryParameter("state", "Login state"),
+				queryParameter("error", "Error reported by the identity provider"),
+			}},
+
+		// Administration
+		{method: http.MethodPost, path: "/admin/api-keys", id: "createAPIKey", tag: "admin",
+			summary: "Issue an API key. The key is only returned in this response", request: service.CreateAPIKeyRequest{},
+			response: service.CreateAPIKeyResponse{}, status: http.StatusCreated, errors: []int{http.StatusBadRequest},
+			protected: true},
+		{method: http.MethodGet, path: "/admin/api-keys", id: "listAPIKeys", tag: "admin",
+			summary: "List API keys", response: []domain.APIKey{}, protected: true},
+		{method: http.MethodDelete, path: "/admin/api-keys/{id}", id: "revokeAPIKey", tag: "admin",
+			summary: "Revoke an API key", errors: []int{http.StatusBadRequest, http.StatusNotFound}, protected: true},
+		{method: http.MethodGet, path: "/admin/network-policies", id: "listNetworkPolicies", tag: "admin",
+			summary: "List the network policy of each role", response: []domain.NetworkPolicy{}, protected: true},
+		{method: http.MethodPut, path: "/admin/network-policies/{role}", id: "updateNetworkPolicy", tag: "admin",
+			summary: "Replace a role's network policy", request: service.UpdateNetworkPolicyRequest{},
+			response: domain.NetworkPolicy{}, errors: []int{http.StatusBadRequest}, protected: true},
+		{method: http.MethodGet, path: "/admin/users/{id}", id: "getUser", tag: "admin",
+			summary: "Get a user. API keys need the users:read scope", response: service.UserResponse{},
+			errors: []int{http.StatusBadRequest, http.StatusNotFound}, protected: true, versioned: true},
+		{method: http.MethodPatch, path: "/admin/users/{id}", id: "updateUser", tag: "admin",
+			summary: "Update a user. Fails with 412 when the user has changed since the ETag in If-Match was read",
+			request: service.UpdateUserRequest{}, response: service.UserResponse{},
+			errors: []int{http.StatusBadRequest, http.StatusNotFound}, protected: true, versioned: true},
+		{method: http.MethodDelete, path: "/admin/users/{id}", id: "deleteUser", tag: "admin",
+			summary: "Delete a user. The user can be restored until the retention period passes",
+			errors:  []int{http.StatusBadRequest, http.StatusNotFound}, protected: true, versioned: true},
+		{method: http.MethodPost, path: "/admin/users/{id}/restore", id: "restoreUser", tag: "admin",
+			summary:  "Restore a deleted user. Fails with 409 when another user has taken its email meanwhile",
+			response: service.UserResponse{}, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+			protected: true},
+	}
+}
+
+// SpecVersion describes a mounted version of the API for the OpenAPI document
+type SpecVersion struct {
+	Name       string
+	Prefix     string
+	Deprecated bool
+}
+
+// OpenAPISpec builds the OpenAPI document of the API from the request and
+// response types of each route. The API routes are documented once per
+// version they are mounted at.
+func OpenAPISpec(versions ...SpecVersion) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Future Star Center API",
+		Version:     APIVersion,
+		Description: "Authentication and administration API of Future Star Center. Errors are RFC 7807 problem details.",
+	})
+
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"sessionHeader": {Type: "apiKey", In: "header", Name: "X-Session-ID", Description: "Session ID"},
+		"bearer":        {Type: "http", Scheme: "bearer", Description: "Session ID or API key"},
+		"sessionCookie": {Type: "apiKey", In: "cookie", Name: "session_id", Description: "Session cookie; state-changing requests also need the X-CSRF-Token header"},
+		"apiKey":        {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "API key"},
+	}
+
+	b := &specBuilder{
+		doc:      doc,
+		security: []map[string][]string{{"sessionHeader": {}}, {"bearer": {}}, {"sessionCookie": {}}, {"apiKey": {}}},
+		problem:  doc.SchemaFor(Problem{}),
+		envelope: doc.SchemaFor(SuccessResponse{}),
+	}
+
+	for _, r := range operationRoutes(doc) {
+		doc.AddOperation(r.method, r.path, b.operation(r))
+	}
+
+	for _, version := range versions {
+		for _, r := range apiRoutes(doc) {
+			op := b.operation(r)
+			op.OperationID = version.Name + strings.ToUpper(r.id[:1]) + r.id[1:]
+			op.Deprecated = version.Deprecated
+			doc.AddOperation(r.method, version.Prefix+r.path, op)
+		}
+	}
+
+	return doc
+}
+
+// specBuilder turns routes into OpenAPI operations
+type specBuilder struct {
+	doc      *openapi.Document
+	security []map[string][]string
+	problem  *openapi.Schema
+	envelope *openapi.Schema
+}
+
+func (b *specBuilder) operation(r route) *openapi.Operation {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	op := &openapi.Operation{
+		OperationID: r.id,
+		Summary:     r.summary,
+		Tags:        []string{r.tag},
+		Parameters:  r.query,
+		Responses:   map[string]*openapi.Response{},
+	}
+
+	if r.request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: b.doc.SchemaFor(r.request)}},
+		}
+	}
+
+	switch {
+	case r.raw != nil:
+		op.Responses[strconv.Itoa(status)] = r.raw
+	case r.response != nil:
+		op.Responses[strconv.Itoa(status)] = &openapi.Response{
+			Description: http.StatusText(status),
+			Content: map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: &openapi.Schema{AllOf: []*openapi.Schema{
+				b.envelope,
+				{Type: "object", Properties: map[string]*openapi.Schema{"data": b.doc.SchemaFor(r.response)}},
+			}}}},
+		}
+	default:
+		op.Responses[strconv.Itoa(status)] = &openapi.Response{
+			Description: http.StatusText(status),
+			Content:     map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: b.envelope}},
+		}
+	}
+
+	errors := r.errors
+	if r.versioned {
+		// A deleted record has no version left to report
+		if r.method != http.MethodDelete {
+			op.Responses[strconv.Itoa(status)].Headers = map[string]*openapi.Header{
+				HeaderETag: {Description: "Version of the record", Schema: &openapi.Schema{Type: "string"}},
+			}
+		}
+		if r.method != http.MethodGet {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name:        HeaderIfMatch,
+				In:          "header",
+				Description: "ETag of the version the change was made against",
+				Required:    true,
+				Schema:      &openapi.Schema{Type: "string"},
+			})
+			errors = append(errors, http.StatusPreconditionFailed, http.StatusPreconditionRequired)
+		}
+	}
+
+	if r.accepted {
+		op.Responses[strconv.Itoa(http.StatusAccepted)] = &openapi.Response{
+			Description: http.StatusText(http.StatusAccepted),
+			Content:     op.Responses[strconv.Itoa(status)].Content,
+		}
+	}
+
+	if r.protected {
+		op.Security = b.security
+		errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if r.request != nil || r.protected || r.raw == nil {
+		errors = append(errors, http.StatusInternalServerError)
+	}
+	for _, code := range errors {
+		// Probes answer 503 with their own body
+		if r.raw != nil && code == http.StatusServiceUnavailable {
+			op.Responses[strconv.Itoa(code)] = &openapi.Response{
+				Description: "A dependency is down or the server is shutting down",
+				Content:     r.raw.Content,
+			}
+			continue
+		}
+		op.Responses[strconv.Itoa(code)] = &openapi.Response{
+			Description: http.StatusText(code),
+			Content:     map[string]openapi.MediaType{ProblemContentType: {Schema: b.problem}},
+		}
+	}
+
+	return op
+}
+
+func queryParameter(name, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
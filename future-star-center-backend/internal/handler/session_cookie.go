@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/middleware"
+	"future-star-center-backend/internal/service"
+	"future-star-center-backend/pkg/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setSessionCookies sets the HttpOnly session cookie and the CSRF token
+// cookie for browser clients. It does nothing unless cookies are enabled.
+func setSessionCookies(c echo.Context, sessionConfig config.SessionConfig, resp *service.AuthResponse) error {
+	if !sessionConfig.CookieEnabled {
+		return nil
+	}
+
+	csrfToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expires := time.Unix(resp.ExpiresAt, 0)
+	c.SetCookie(newSessionCookie(sessionConfig, middleware.SessionCookieName, resp.SessionID, expires, true))
+	c.SetCookie(newSessionCookie(sessionConfig, middleware.CSRFCookieName, csrfToken, expires, false))
+
+	// Also expose the token for clients served from another origin, which
+	// cannot read the API's cookies
+	c.Response().Header().Set(middleware.CSRFHeaderName, csrfToken)
+	return nil
+}
+
+// clearSessionCookies expires the session and CSRF token cookies
+func clearSessionCookies(c echo.Context, sessionConfig config.SessionConfig) {
+	if !sessionConfig.CookieEnabled {
+		return
+	}
+
+	c.SetCookie(newSessionCookie(sessionConfig, middleware.SessionCookieName, "", time.Unix(0, 0), true))
+	c.SetCookie(newSessionCookie(sessionConfig, middleware.CSRFCookieName, "", time.Unix(0, 0), false))
+}
+
+func newSessionCookie(sessionConfig config.SessionConfig, name, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   sessionConfig.CookieDomain,
+		Expires:  expires,
+		Secure:   sessionConfig.CookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: parseSameSite(sessionConfig.CookieSameSite),
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
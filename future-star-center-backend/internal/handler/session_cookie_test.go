@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/middleware"
+	"future-star-center-backend/internal/service"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSessionCookies(t *testing.T) {
+	s := newConfiguredTestServer(t, func(cfg *config.Config) {
+		cfg.Session.CookieEnabled = true
+		cfg.Session.CookieDomain = "example.com"
+		cfg.Session.CookieSecure = true
+		cfg.Session.CookieSameSite = "lax"
+	})
+	s.register(t, "ayu@example.com")
+
+	rec := s.do(http.MethodPost, "/auth/login", `{"email":"ayu@example.com","password":"correct-horse-battery"}`, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp struct {
+		Data service.AuthResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode login response: %v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	session := findCookie(cookies, middleware.SessionCookieName)
+	csrf := findCookie(cookies, middleware.CSRFCookieName)
+	if session == nil || csrf == nil {
+		t.Fatalf("cookies = %v, want the session and CSRF cookies", cookies)
+	}
+
+	expires := time.Unix(resp.Data.ExpiresAt, 0)
+	for _, cookie := range []*http.Cookie{session, csrf} {
+		if cookie.Path != "/" || cookie.Domain != "example.com" || !cookie.Secure ||
+			cookie.SameSite != http.SameSiteLaxMode || !cookie.Expires.Equal(expires) {
+			t.Errorf("%s cookie = %+v, want Path=/, Domain=example.com, Secure, SameSite=Lax and Expires=%v",
+				cookie.Name, cookie, expires)
+		}
+	}
+	if session.Value != resp.Data.SessionID || !session.HttpOnly {
+		t.Errorf("session cookie = %+v, want the HttpOnly session ID", session)
+	}
+	// The web client reads the token from the cookie, or from the header
+	// when it is served from another origin
+	if csrf.Value == "" || csrf.HttpOnly {
+		t.Errorf("CSRF cookie = %+v, want a token readable by scripts", csrf)
+	}
+	if header := rec.Header().Get(middleware.CSRFHeaderName); header != csrf.Value {
+		t.Errorf("%s header = %q, want the cookie's token %q", middleware.CSRFHeaderName, header, csrf.Value)
+	}
+
+	rec = s.do(http.MethodPost, "/auth/logout", "", resp.Data.SessionID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	for _, name := range []string{middleware.SessionCookieName, middleware.CSRFCookieName} {
+		if cleared := findCookie(rec.Result().Cookies(), name); cleared == nil || cleared.MaxAge >= 0 {
+			t.Errorf("%s cookie after logout = %+v, want it deleted", name, cleared)
+		}
+	}
+}
+
+func TestSessionCookiesDisabled(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "ayu@example.com")
+
+	rec := s.do(http.MethodPost, "/auth/login", `{"email":"ayu@example.com","password":"correct-horse-battery"}`, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("cookies = %v, want none", cookies)
+	}
+	if header := rec.Header().Get(middleware.CSRFHeaderName); header != "" {
+		t.Errorf("%s header = %q, want none", middleware.CSRFHeaderName, header)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// UserHandler handles user administration HTTP requests. Users carry their
+// version as an ETag, which updates must send back in If-Match.
+type UserHandler struct {
+	userService service.UserService
+	validator   *requestValidator
+	logger      *slog.Logger
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(userService service.UserService, logger *slog.Logger) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+		validator:   newRequestValidator(),
+		logger:      logger,
+	}
+}
+
+// Get handles fetching a user
+func (h *UserHandler) Get(c echo.Context) error {
+	user, err := h.userService.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	setETag(c, user.Version)
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "User retrieved",
+		Data:    user,
+	})
+}
+
+// Update handles a partial update of a user at the version given in If-Match
+func (h *UserHandler) Update(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	var req service.UpdateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.ErrInvalidRequestBody
+	}
+
+	if err := h.validator.Validate(c, req); err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id").(string)
+	user, err := h.userService.Update(c.Request().Context(), c.Param("id"), version, req, userID)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, user.Version)
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "User updated",
+		Data:    user,
+	})
+}
+
+// Delete handles deleting a user at the version given in If-Match. The user
+// can be restored until the retention period passes.
+func (h *UserHandler) Delete(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := c.Get("user_id").(string)
+	err = h.userService.Delete(c.Request().Context(), c.Param("id"), version, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "User deleted",
+	})
+}
+
+// Restore handles restoring a deleted user
+func (h *UserHandler) Restore(c echo.Context) error {
+	userID, _ := c.Get("user_id").(string)
+	user, err := h.userService.Restore(c.Request().Context(), c.Param("id"), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, SuccessResponse{
+		Message: "User restored",
+		Data:    user,
+	})
+}
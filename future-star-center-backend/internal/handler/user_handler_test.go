@@ -0,0 +1,226 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"future-star-center-backend/internal/handler"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// registeredUserPath registers a user and returns its admin path and ETag
+func registeredUserPath(t *testing.T, s *testServer) (string, string) {
+	t.Helper()
+
+	s.register(t, "ayu@example.com")
+	user, err := s.users.GetByEmail(context.Background(), "ayu@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() error = %v", err)
+	}
+
+	path := "/admin/users/" + user.ID.Hex()
+	rec := s.do(http.MethodGet, path, "", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d: %s", path, rec.Code, rec.Body)
+	}
+	return path, rec.Header().Get(handler.HeaderETag)
+}
+
+func TestUserHandlerGet(t *testing.T) {
+	s := newTestServer(t)
+	path, etag := registeredUserPath(t, s)
+	if etag != `"1"` {
+		t.Errorf("ETag = %s, want \"1\"", etag)
+	}
+
+	// Logins are not edits, so they leave the version alone
+	s.users.UpdateLastLogin(context.Background(), strings.TrimPrefix(path, "/admin/users/"))
+	rec := s.do(http.MethodGet, path, "", "", nil)
+	if got := rec.Header().Get(handler.HeaderETag); got != `"1"` {
+		t.Errorf("ETag after a login = %s, want \"1\"", got)
+	}
+
+	rec = s.do(http.MethodGet, "/admin/users/"+primitive.NewObjectID().Hex(), "", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET unknown user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUserHandlerUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		// ifMatch builds the If-Match header from the ETag the user was read at
+		ifMatch func(etag string) string
+		// between runs after the user was read and before it is updated
+		between    func(t *testing.T, s *testServer, path string)
+		body       string
+		wantStatus int
+		wantCode   string
+		wantETag   string
+	}{
+		{
+			name:       "applies a change to the current version",
+			ifMatch:    func(etag string) string { return etag },
+			body:       `{"role":"admin","is_active":false}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"2"`,
+		},
+		{
+			name:       "leaves the version of an unchanged user",
+			ifMatch:    func(etag string) string { return etag },
+			body:       `{"first_name":"Ayu"}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:    "rejects a version changed by another admin",
+			ifMatch: func(etag string) string { return etag },
+			between: func(t *testing.T, s *testServer, path string) {
+				rec := s.do(http.MethodPatch, path, `{"last_name":"Pratiwi"}`, "", http.Header{"If-Match": {`"1"`}})
+				if rec.Code != http.StatusOK {
+					t.Fatalf("first update status = %d: %s", rec.Code, rec.Body)
+				}
+			},
+			body:       `{"first_name":"Ayunda"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   "version_mismatch",
+		},
+		{
+			name:       "rejects a weak ETag",
+			ifMatch:    func(etag string) string { return "W/" + etag },
+			body:       `{"first_name":"Ayunda"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   "version_mismatch",
+		},
+		{
+			name:       "rejects any version",
+			ifMatch:    func(etag string) string { return "*" },
+			body:       `{"first_name":"Ayunda"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   "version_mismatch",
+		},
+		{
+			name:       "requires If-Match",
+			ifMatch:    func(etag string) string { return "" },
+			body:       `{"first_name":"Ayunda"}`,
+			wantStatus: http.StatusPreconditionRequired,
+			wantCode:   "if_match_required",
+		},
+		{
+			name:       "rejects an unknown role",
+			ifMatch:    func(etag string) string { return etag },
+			body:       `{"role":"owner"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_role",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			path, etag := registeredUserPath(t, s)
+			if tt.between != nil {
+				tt.between(t, s, path)
+			}
+
+			header := http.Header{}
+			if ifMatch := tt.ifMatch(etag); ifMatch != "" {
+				header.Set(handler.HeaderIfMatch, ifMatch)
+			}
+			rec := s.do(http.MethodPatch, path, tt.body, "", header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get(handler.HeaderETag); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var problem handler.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Errorf("problem code = %s, want %s", problem.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestUserHandlerUpdateEndsSessions(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantSession bool
+	}{
+		{name: "ends the sessions of a user whose role changed", body: `{"role":"staff"}`},
+		{name: "ends the sessions of a deactivated user", body: `{"is_active":false}`},
+		{name: "keeps the sessions of a renamed user", body: `{"first_name":"Ayunda"}`, wantSession: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			sessionID := s.register(t, "ayu@example.com")
+			user, _ := s.users.GetByEmail(context.Background(), "ayu@example.com")
+			path := "/admin/users/" + user.ID.Hex()
+
+			rec := s.do(http.MethodPatch, path, tt.body, "", http.Header{"If-Match": {`"1"`}})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("PATCH %s status = %d: %s", path, rec.Code, rec.Body)
+			}
+
+			wantStatus := http.StatusUnauthorized
+			if tt.wantSession {
+				wantStatus = http.StatusOK
+			}
+			rec = s.do(http.MethodGet, "/auth/session", "", sessionID, nil)
+			if rec.Code != wantStatus {
+				t.Errorf("GET /auth/session status = %d, want %d", rec.Code, wantStatus)
+			}
+		})
+	}
+}
+
+func TestUserHandlerDeleteAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	sessionID := s.register(t, "ayu@example.com")
+	user, _ := s.users.GetByEmail(context.Background(), "ayu@example.com")
+	path := "/admin/users/" + user.ID.Hex()
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		sessionID  string
+		ifMatch    string
+		wantStatus int
+	}{
+		{"refuses to delete without If-Match", http.MethodDelete, path, "", "", http.StatusPreconditionRequired},
+		{"refuses to delete a stale version", http.MethodDelete, path, "", `"2"`, http.StatusPreconditionFailed},
+		{"deletes the user", http.MethodDelete, path, "", `"1"`, http.StatusOK},
+		{"ends the user's sessions", http.MethodGet, "/auth/session", sessionID, "", http.StatusUnauthorized},
+		{"hides the deleted user", http.MethodGet, path, "", "", http.StatusNotFound},
+		{"refuses to delete the user twice", http.MethodDelete, path, "", `"2"`, http.StatusNotFound},
+		{"restores the user", http.MethodPost, path + "/restore", "", "", http.StatusOK},
+		{"shows the restored user", http.MethodGet, path, "", "", http.StatusOK},
+		{"refuses to restore a live user", http.MethodPost, path + "/restore", "", "", http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		var header http.Header
+		if step.ifMatch != "" {
+			header = http.Header{"If-Match": {step.ifMatch}}
+		}
+		rec := s.do(step.method, step.path, "", step.sessionID, header)
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: %s %s status = %d, want %d: %s",
+				step.name, step.method, step.path, rec.Code, step.wantStatus, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"future-star-center-backend/internal/domain"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+)
+
+// validationFailedKey is the translation key of the problem detail
+const validationFailedKey = "validation_failed"
+
+// requestValidator validates request bodies and reports every failing field
+// with a message in the language asked for by Accept-Language
+type requestValidator struct {
+	validate   *validator.Validate
+	translator *ut.UniversalTranslator
+}
+
+// newRequestValidator creates a validator with English and Indonesian
+// messages. English is the fallback for any other language.
+func newRequestValidator() *requestValidator {
+	validate := validator.New()
+
+	// Report fields by their JSON names, as clients know them
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	english := en.New()
+	translator := ut.New(english, english, id.New())
+
+	registrations := []struct {
+		locale   string
+		register func(*validator.Validate, ut.Translator) error
+		detail   string
+	}{
+		{"en", en_translations.RegisterDefaultTranslations, "request validation failed"},
+		{"id", id_translations.RegisterDefaultTranslations, "validasi permintaan gagal"},
+	}
+	for _, r := range registrations {
+		trans, _ := translator.GetTranslator(r.locale)
+		// Registration only fails on malformed built-in templates
+		if err := r.register(validate, trans); err != nil {
+			panic("failed to register " + r.locale + " validation messages: " + err.Error())
+		}
+		if err := trans.Add(validationFailedKey, r.detail, false); err != nil {
+			panic("failed to register " + r.locale + " validation messages: " + err.Error())
+		}
+	}
+
+	return &requestValidator{
+		validate:   validate,
+		translator: translator,
+	}
+}
+
+// Validate checks the request body, returning a validation error that lists
+// each failing field
+func (v *requestValidator) Validate(c echo.Context, req interface{}) error {
+	err := v.validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	trans := v.translatorFor(c.Request().Header.Get("Accept-Language"))
+	fields := make([]domain.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, domain.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: fe.Translate(trans),
+		})
+	}
+
+	detail, _ := trans.T(validationFailedKey)
+	return domain.NewFieldValidationError(detail, fields)
+}
+
+// translatorFor picks the translator for the most preferred supported
+// language of an Accept-Language header
+func (v *requestValidator) translatorFor(acceptLanguage string) ut.Translator {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+
+	trans, _ := v.translator.FindTranslator(locales...)
+	return trans
+}
+
+// fieldPath is the field's JSON path without the request type, e.g. "email"
+// or "allow[0]"
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// hook is a named shutdown step
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager runs the application until it receives SIGINT or SIGTERM, then
+// shuts it down in three phases within a single deadline:
+//
+//  1. stop hooks run in registration order, e.g. failing readiness and
+//     draining in-flight HTTP requests
+//  2. background workers are cancelled and waited for
+//  3. close hooks run in reverse registration order, so clients opened
+//     first are closed last
+type Manager struct {
+	timeout time.Duration
+	logger  *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	stopHooks  []hook
+	closeHooks []hook
+	workers    sync.WaitGroup
+}
+
+// New creates a lifecycle manager whose shutdown must finish within timeout
+func New(timeout time.Duration, logger *slog.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		timeout: timeout,
+		logger:  logger,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// OnStop registers a step that stops new work from being accepted
+func (m *Manager) OnStop(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopHooks = append(m.stopHooks, hook{name: name, fn: fn})
+}
+
+// OnClose registers a step that releases a resource once all work is done
+func (m *Manager) OnClose(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closeHooks = append(m.closeHooks, hook{name: name, fn: fn})
+}
+
+// Go runs a background worker. Its context is cancelled when shutdown starts
+// and shutdown waits for it to return before closing any resource.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				m.logger.Error("background worker panicked", "worker", name, "panic", r)
+			}
+		}()
+		fn(m.ctx)
+	}()
+}
+
+// Wait blocks until SIGINT or SIGTERM is received and then shuts down. A
+// second signal kills the process without waiting.
+func (m *Manager) Wait() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	m.logger.Info("shutdown signal received", "timeout", m.timeout.String())
+	return m.Shutdown()
+}
+
+// Shutdown runs the shutdown phases. Steps keep running after one fails or
+// the deadline passes so every resource gets a chance to close, and all
+// failures are returned together.
+func (m *Manager) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	stopHooks := m.stopHooks
+	closeHooks := m.closeHooks
+	m.mu.Unlock()
+
+	var errs []error
+	for _, h := range stopHooks {
+		errs = append(errs, m.run(ctx, h))
+	}
+
+	m.cancel()
+	errs = append(errs, m.waitForWorkers(ctx))
+
+	for i := len(closeHooks) - 1; i >= 0; i-- {
+		errs = append(errs, m.run(ctx, closeHooks[i]))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) run(ctx context.Context, h hook) error {
+	start := time.Now()
+	if err := h.fn(ctx); err != nil {
+		m.logger.Error("shutdown step failed", "step", h.name, "error", err)
+		return fmt.Errorf("%s: %w", h.name, err)
+	}
+	m.logger.Info("shutdown step complete", "step", h.name, "duration", time.Since(start).String())
+	return nil
+}
+
+func (m *Manager) waitForWorkers(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.logger.Error("background workers did not finish before the shutdown deadline")
+		return fmt.Errorf("background workers: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,184 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/lifecycle"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the steps a shutdown runs, in order
+type recorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (r *recorder) record(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *recorder) hook(step string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.record(step)
+		return nil
+	}
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.steps, ", ")
+}
+
+func newManager(timeout time.Duration) *lifecycle.Manager {
+	return lifecycle.New(timeout, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestManagerShutdownOrder(t *testing.T) {
+	m := newManager(time.Second)
+	steps := &recorder{}
+
+	m.OnClose("mongo", steps.hook("close mongo"))
+	m.OnStop("readiness", steps.hook("stop readiness"))
+	m.OnClose("redis", steps.hook("close redis"))
+	m.OnStop("http server", steps.hook("stop http server"))
+	m.Go("purge", func(ctx context.Context) {
+		<-ctx.Done()
+		// Give a close hook that did not wait for the worker time to run
+		time.Sleep(10 * time.Millisecond)
+		steps.record("worker returned")
+	})
+
+	if err := m.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// Stop hooks run in order, then the worker is cancelled and awaited, then
+	// close hooks run in reverse
+	want := "stop readiness, stop http server, worker returned, close redis, close mongo"
+	if got := steps.String(); got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+}
+
+func TestManagerShutdownCancelsWorkersAfterStopHooks(t *testing.T) {
+	m := newManager(time.Second)
+
+	cancelled := make(chan struct{})
+	m.Go("purge", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	m.OnStop("http server", func(ctx context.Context) error {
+		select {
+		case <-cancelled:
+			return errors.New("worker cancelled while requests were still draining")
+		case <-time.After(10 * time.Millisecond):
+			return nil
+		}
+	})
+
+	if err := m.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Error("worker was not cancelled")
+	}
+}
+
+func TestManagerShutdownSharesOneDeadline(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	m := newManager(timeout)
+	steps := &recorder{}
+
+	var deadlines []time.Time
+	deadline := func(ctx context.Context) {
+		d, ok := ctx.Deadline()
+		if !ok {
+			t.Error("hook context has no deadline")
+		}
+		deadlines = append(deadlines, d)
+	}
+
+	// A stop hook that outlives the deadline, and a worker that ignores its
+	// cancellation, leave the close hooks an expired context
+	m.OnStop("http server", func(ctx context.Context) error {
+		deadline(ctx)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(ctx context.Context) { <-release })
+	m.OnClose("mongo", func(ctx context.Context) error {
+		deadline(ctx)
+		steps.record("close mongo")
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	err := m.Shutdown()
+	if elapsed := time.Since(start); elapsed > 10*timeout {
+		t.Errorf("Shutdown() took %v, want about %v", elapsed, timeout)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	for _, step := range []string{"http server", "background workers", "mongo"} {
+		if err == nil || !strings.Contains(err.Error(), step) {
+			t.Errorf("Shutdown() error = %v, want it to name %s", err, step)
+		}
+	}
+	// Close hooks still run after the deadline passes
+	if got := steps.String(); got != "close mongo" {
+		t.Errorf("steps = %s, want close mongo", got)
+	}
+	if len(deadlines) != 2 || !deadlines[0].Equal(deadlines[1]) {
+		t.Errorf("hook deadlines = %v, want the same deadline for every hook", deadlines)
+	}
+}
+
+func TestManagerShutdownJoinsErrors(t *testing.T) {
+	m := newManager(time.Second)
+	steps := &recorder{}
+
+	errDrain := errors.New("drain failed")
+	errRedis := errors.New("redis close failed")
+	m.OnStop("http server", func(ctx context.Context) error { return errDrain })
+	m.OnStop("metrics server", steps.hook("stop metrics server"))
+	m.OnClose("mongo", steps.hook("close mongo"))
+	m.OnClose("redis", func(ctx context.Context) error { return errRedis })
+
+	err := m.Shutdown()
+	if !errors.Is(err, errDrain) || !errors.Is(err, errRedis) {
+		t.Fatalf("Shutdown() error = %v, want both failures", err)
+	}
+	if !strings.Contains(err.Error(), "http server: drain failed") || !strings.Contains(err.Error(), "redis: redis close failed") {
+		t.Errorf("Shutdown() error = %q, want each failure prefixed by its step", err)
+	}
+
+	// A failing step does not stop the others
+	if got, want := steps.String(), "stop metrics server, close mongo"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+}
+
+func TestManagerRecoversWorkerPanics(t *testing.T) {
+	m := newManager(time.Second)
+	m.Go("panicking", func(ctx context.Context) { panic("boom") })
+
+	if err := m.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
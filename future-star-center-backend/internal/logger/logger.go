@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// requestIDKey is the context key holding the current request ID
+var requestIDKey = contextKey{}
+
+// redactedKeys lists attribute keys whose values are never logged
+var redactedKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"session_id":    true,
+	"api_key":       true,
+	"secret":        true,
+	"code":          true,
+	"authorization": true,
+	"cookie":        true,
+}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// New creates the application logger. Production logs are JSON, other
+// environments use the human readable text format. Sensitive attributes are
+// redacted and emails are masked in every record.
+func New(w io.Writer, env, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redact,
+	}
+
+	var handler slog.Handler
+	if env == "production" {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// WithRequestID returns a context carrying the request ID, which is added to
+// every record logged with that context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID carried by the context, if any
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// MaskEmail masks the local part of every email address in a string
+func MaskEmail(value string) string {
+	return emailPattern.ReplaceAllString(value, "$1***@$2")
+}
+
+// contextHandler adds the request ID and trace ID from the context to each
+// record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	record.Message = MaskEmail(record.Message)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "[REDACTED]")
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, MaskEmail(attr.Value.String()))
+	case slog.KindAny:
+		// Errors often embed the email or token that caused them
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, MaskEmail(err.Error()))
+		}
+	}
+
+	return attr
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,163 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/logger"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecord logs one record through a production logger and returns it
+// decoded from its JSON line
+func logRecord(t *testing.T, log func(l *slog.Logger)) map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log(logger.New(&buf, "production", "debug"))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestLoggerRedactsSensitiveKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		key  string
+	}{
+		{
+			name: "password",
+			log:  func(l *slog.Logger) { l.Info("login", "password", "correct-horse-battery") },
+			key:  "password",
+		},
+		{
+			name: "keys in any case",
+			log:  func(l *slog.Logger) { l.Info("request", "Authorization", "Bearer fsc_abc_secret") },
+			key:  "Authorization",
+		},
+		{
+			name: "session ID",
+			log:  func(l *slog.Logger) { l.Info("session", "session_id", "0b6f0c1e") },
+			key:  "session_id",
+		},
+		{
+			name: "verification code",
+			log:  func(l *slog.Logger) { l.Info("step-up", "code", "123456") },
+			key:  "code",
+		},
+		{
+			name: "values that are not strings",
+			log:  func(l *slog.Logger) { l.Info("step-up", "code", 123456) },
+			key:  "code",
+		},
+		{
+			name: "attributes added with With",
+			log:  func(l *slog.Logger) { l.With("api_key", "fsc_abc_secret").Info("request") },
+			key:  "api_key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := logRecord(t, tt.log)
+			if record[tt.key] != "[REDACTED]" {
+				t.Errorf("%s = %v, want [REDACTED]", tt.key, record[tt.key])
+			}
+		})
+	}
+}
+
+func TestLoggerRedactsKeysInGroups(t *testing.T) {
+	record := logRecord(t, func(l *slog.Logger) {
+		l.Info("request", slog.Group("headers", "cookie", "session_id=0b6f0c1e", "accept", "application/json"))
+	})
+
+	headers, _ := record["headers"].(map[string]any)
+	if headers["cookie"] != "[REDACTED]" || headers["accept"] != "application/json" {
+		t.Errorf("headers = %v, want only the cookie redacted", headers)
+	}
+}
+
+func TestLoggerMasksEmails(t *testing.T) {
+	const masked = "a***@example.com"
+
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		key  string
+		want string
+	}{
+		{
+			name: "string attributes",
+			log:  func(l *slog.Logger) { l.Info("login", "email", "ayu.lestari@example.com") },
+			key:  "email",
+			want: masked,
+		},
+		{
+			name: "error attributes",
+			log: func(l *slog.Logger) {
+				l.Error("send failed", "error", fmt.Errorf("send to ayu.lestari@example.com: %w", errors.New("mailbox full")))
+			},
+			key:  "error",
+			want: "send to " + masked + ": mailbox full",
+		},
+		{
+			name: "messages",
+			log:  func(l *slog.Logger) { l.Info("invited ayu.lestari@example.com") },
+			key:  slog.MessageKey,
+			want: "invited " + masked,
+		},
+		{
+			name: "every address in a value",
+			log:  func(l *slog.Logger) { l.Info("merge", "users", "ayu@example.com, budi@example.org") },
+			key:  "users",
+			want: masked + ", b***@example.org",
+		},
+		{
+			name: "values without an email",
+			log:  func(l *slog.Logger) { l.Info("login", "user_id", "65f1c0ffee") },
+			key:  "user_id",
+			want: "65f1c0ffee",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := logRecord(t, tt.log)
+			if record[tt.key] != tt.want {
+				t.Errorf("%s = %v, want %q", tt.key, record[tt.key], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	record := logRecord(t, func(l *slog.Logger) {
+		l.InfoContext(logger.WithRequestID(context.Background(), "req-1"), "handled")
+	})
+
+	if record["request_id"] != "req-1" {
+		t.Errorf("request_id = %v, want req-1", record["request_id"])
+	}
+}
+
+func TestLoggerFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(&buf, "development", "warn")
+	l.Info("dropped")
+	l.Warn("kept", "email", "ayu@example.com")
+
+	// Development logs are text, filtered by level and still masked
+	if got := buf.String(); strings.Contains(got, "dropped") ||
+		!strings.Contains(got, "msg=kept") || !strings.Contains(got, "email=a***@example.com") {
+		t.Errorf("log = %q, want only the masked warning as text", got)
+	}
+}
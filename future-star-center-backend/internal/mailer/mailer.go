@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"future-star-center-backend/internal/config"
+	"log/slog"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer defines the interface for sending emails
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// New creates an SMTP mailer, or a log mailer when no SMTP host is configured
+func New(cfg config.SMTPConfig, logger *slog.Logger) Mailer {
+	if cfg.Host == "" {
+		return &logMailer{logger: logger}
+	}
+	return &smtpMailer{config: cfg}
+}
+
+type smtpMailer struct {
+	config config.SMTPConfig
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", m.config.Host, m.config.Port)
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.config.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(addr, auth, m.config.From, []string{to}, []byte(message))
+}
+
+// logMailer logs that an email would have been sent, for local development.
+// Bodies carry reset tokens and login codes, so they are never logged.
+type logMailer struct {
+	logger *slog.Logger
+}
+
+func (m *logMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.logger.InfoContext(ctx, "email not sent, SMTP is not configured", "to", to, "subject", subject)
+	return nil
+}
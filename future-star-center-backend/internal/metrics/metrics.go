@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "future_star"
+
+// Login outcomes
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+	LoginStepUp    = "step_up_required"
+)
+
+// Cache lookup results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+// Metrics holds the application's Prometheus collectors. All methods are safe
+// to call on a nil *Metrics, which records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequestDuration    *prometheus.HistogramVec
+	storeOperationDuration *prometheus.HistogramVec
+	loginsTotal            *prometheus.CounterVec
+	passwordResetsTotal    prometheus.Counter
+	apiVersionRequests     *prometheus.CounterVec
+	userCacheLookups       *prometheus.CounterVec
+	mongoPoolConnections   *prometheus.GaugeVec
+}
+
+// New creates the application metrics on a dedicated registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storeOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "MongoDB and Redis operation latency.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"store", "operation", "outcome"}),
+		loginsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by method and outcome.",
+		}, []string{"method", "outcome"}),
+		passwordResetsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "password_resets_requested_total",
+			Help:      "Password reset requests.",
+		}),
+		apiVersionRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_version_requests_total",
+			Help:      "API requests by API version, to track the use of deprecated versions.",
+		}, []string{"version", "deprecated"}),
+		userCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "user_cache_lookups_total",
+			Help:      "User cache lookups by result: hit, miss or error.",
+		}, []string{"result"}),
+		mongoPoolConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mongo_pool_connections",
+			Help:      "MongoDB connection pool connections by state.",
+		}, []string{"state"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestDuration,
+		m.storeOperationDuration,
+		m.loginsTotal,
+		m.passwordResetsTotal,
+		m.apiVersionRequests,
+		m.userCacheLookups,
+		m.mongoPoolConnections,
+	)
+
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records the duration of an HTTP request
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveStoreOperation records the duration of a MongoDB or Redis operation
+func (m *Metrics) ObserveStoreOperation(store, operation string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	m.storeOperationDuration.WithLabelValues(store, operation, outcome).Observe(duration.Seconds())
+}
+
+// RecordLogin counts a login attempt
+func (m *Metrics) RecordLogin(method, outcome string) {
+	if m == nil {
+		return
+	}
+	m.loginsTotal.WithLabelValues(method, outcome).Inc()
+}
+
+// RecordPasswordResetRequested counts a password reset request
+func (m *Metrics) RecordPasswordResetRequested() {
+	if m == nil {
+		return
+	}
+	m.passwordResetsTotal.Inc()
+}
+
+// RecordAPIVersionRequest counts a request to a version of the API
+func (m *Metrics) RecordAPIVersionRequest(version string, deprecated bool) {
+	if m == nil {
+		return
+	}
+	m.apiVersionRequests.WithLabelValues(version, strconv.FormatBool(deprecated)).Inc()
+}
+
+// RecordUserCacheLookup counts a user cache lookup by result
+func (m *Metrics) RecordUserCacheLookup(result string) {
+	if m == nil {
+		return
+	}
+	m.userCacheLookups.WithLabelValues(result).Inc()
+}
+
+// activeSessionsMaxAge is how long a session count is reported before it is
+// counted again. Counting can scan the whole session store, so scrapes must
+// not trigger it every time.
+const activeSessionsMaxAge = time.Minute
+
+// RegisterActiveSessions exposes the number of active sessions, counted at
+// most once per activeSessionsMaxAge
+func (m *Metrics) RegisterActiveSessions(count func(ctx context.Context) (int64, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&countCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "active_sessions"),
+			"Sessions that have not expired or been logged out.",
+			nil, nil,
+		),
+		count:  count,
+		maxAge: activeSessionsMaxAge,
+	})
+}
+
+// countCollector reports a gauge computed on demand and reused until it is
+// maxAge old, skipping it when the count fails rather than reporting a
+// misleading zero
+type countCollector struct {
+	desc   *prometheus.Desc
+	count  func(ctx context.Context) (int64, error)
+	maxAge time.Duration
+
+	// mu is held while counting, so that concurrent scrapes count once
+	mu        sync.Mutex
+	value     int64
+	countedAt time.Time
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.countedAt.IsZero() || time.Since(c.countedAt) >= c.maxAge {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		count, err := c.count(ctx)
+		if err != nil {
+			return
+		}
+		c.value, c.countedAt = count, time.Now()
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(c.value))
+}
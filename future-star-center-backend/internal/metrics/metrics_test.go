@@ -0,0 +1,94 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the metrics m exposes, in the text format
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	return rec.Body.String()
+}
+
+func TestMetricsRecord(t *testing.T) {
+	m := metrics.New()
+	m.ObserveHTTPRequest(http.MethodGet, "/api/v1/users/:id", http.StatusOK, 20*time.Millisecond)
+	m.ObserveStoreOperation("mongodb", "users.find", nil, time.Millisecond)
+	m.ObserveStoreOperation("redis", "sessions.get", errors.New("connection refused"), time.Millisecond)
+	m.RecordLogin("password", metrics.LoginFailed)
+	m.RecordLogin("password", metrics.LoginFailed)
+	m.RecordPasswordResetRequested()
+	m.RecordAPIVersionRequest("unversioned", true)
+	m.RecordUserCacheLookup(metrics.CacheHit)
+
+	got := scrape(t, m)
+	for _, want := range []string{
+		`future_star_http_request_duration_seconds_count{method="GET",route="/api/v1/users/:id",status="200"} 1`,
+		`future_star_store_operation_duration_seconds_count{operation="users.find",outcome="success",store="mongodb"} 1`,
+		`future_star_store_operation_duration_seconds_count{operation="sessions.get",outcome="error",store="redis"} 1`,
+		`future_star_logins_total{method="password",outcome="failed"} 2`,
+		`future_star_password_resets_requested_total 1`,
+		`future_star_api_version_requests_total{deprecated="true",version="unversioned"} 1`,
+		`future_star_user_cache_lookups_total{result="hit"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
+
+func TestMetricsNil(t *testing.T) {
+	// Components built without metrics hold a nil *Metrics
+	var m *metrics.Metrics
+	m.ObserveHTTPRequest(http.MethodGet, "/", http.StatusOK, time.Millisecond)
+	m.ObserveStoreOperation("mongodb", "users.find", nil, time.Millisecond)
+	m.RecordLogin("password", metrics.LoginSucceeded)
+	m.RecordPasswordResetRequested()
+	m.RecordAPIVersionRequest("v1", false)
+	m.RecordUserCacheLookup(metrics.CacheMiss)
+	m.RegisterActiveSessions(func(ctx context.Context) (int64, error) { return 0, nil })
+}
+
+func TestMetricsActiveSessions(t *testing.T) {
+	t.Run("counts once across scrapes", func(t *testing.T) {
+		m := metrics.New()
+		counts := 0
+		m.RegisterActiveSessions(func(ctx context.Context) (int64, error) {
+			counts++
+			return 42, nil
+		})
+
+		for range 3 {
+			if got := scrape(t, m); !strings.Contains(got, "future_star_active_sessions 42") {
+				t.Errorf("metrics do not report 42 active sessions")
+			}
+		}
+		if counts != 1 {
+			t.Errorf("sessions counted %d times, want 1", counts)
+		}
+	})
+
+	t.Run("skips a failed count", func(t *testing.T) {
+		m := metrics.New()
+		m.RegisterActiveSessions(func(ctx context.Context) (int64, error) {
+			return 0, errors.New("connection refused")
+		})
+
+		if got := scrape(t, m); strings.Contains(got, "future_star_active_sessions") {
+			t.Error("metrics report active sessions, want none when counting fails")
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoCommandMonitor returns a driver command monitor timing every MongoDB
+// operation issued by the repositories
+func (m *Metrics) MongoCommandMonitor() *event.CommandMonitor {
+	if m == nil {
+		return nil
+	}
+
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			m.ObserveStoreOperation("mongo", e.CommandName, nil, e.Duration)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			m.ObserveStoreOperation("mongo", e.CommandName, errors.New(e.Failure), e.Duration)
+		},
+	}
+}
+
+// MongoPoolMonitor returns a driver pool monitor tracking open and checked
+// out connections
+func (m *Metrics) MongoPoolMonitor() *event.PoolMonitor {
+	if m == nil {
+		return nil
+	}
+
+	open := m.mongoPoolConnections.WithLabelValues("open")
+	inUse := m.mongoPoolConnections.WithLabelValues("in_use")
+
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				open.Inc()
+			case event.ConnectionClosed:
+				open.Dec()
+			case event.GetSucceeded:
+				inUse.Inc()
+			case event.ConnectionReturned:
+				inUse.Dec()
+			}
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// InstrumentRedis times every Redis command issued through the client and
+// exposes its connection pool statistics
+func (m *Metrics) InstrumentRedis(client redis.UniversalClient) {
+	if m == nil {
+		return
+	}
+
+	client.AddHook(&redisHook{metrics: m})
+	m.registry.MustRegister(newRedisPoolCollector(client))
+}
+
+type redisHook struct {
+	metrics *Metrics
+}
+
+func (h *redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		h.metrics.ObserveStoreOperation("redis", "dial", err, time.Since(start))
+		return conn, err
+	}
+}
+
+func (h *redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.metrics.ObserveStoreOperation("redis", cmd.Name(), redisError(err), time.Since(start))
+		return err
+	}
+}
+
+func (h *redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.metrics.ObserveStoreOperation("redis", "pipeline", redisError(err), time.Since(start))
+		return err
+	}
+}
+
+// redisError ignores redis.Nil, which only reports a missing key
+func redisError(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// redisPoolCollector reads the client's pool statistics on each scrape
+type redisPoolCollector struct {
+	client      redis.UniversalClient
+	connections *prometheus.Desc
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	timeouts    *prometheus.Desc
+}
+
+func newRedisPoolCollector(client redis.UniversalClient) *redisPoolCollector {
+	return &redisPoolCollector{
+		client: client,
+		connections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "connections"),
+			"Redis connection pool connections by state.",
+			[]string{"state"}, nil,
+		),
+		hits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "hits_total"),
+			"Times a free connection was found in the pool.",
+			nil, nil,
+		),
+		misses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "misses_total"),
+			"Times a free connection was not found in the pool.",
+			nil, nil,
+		),
+		timeouts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "timeouts_total"),
+			"Times waiting for a connection timed out.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.StaleConns), "stale")
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"future-star-center-backend/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIVersion describes a mounted version of the API
+type APIVersion struct {
+	// Name identifies the version in metrics and logs, e.g. "v1"
+	Name string
+	// Prefix is the path the version is mounted at, e.g. "/api/v1"
+	Prefix string
+	// DeprecatedAt is when the version was deprecated; zero while it is current
+	DeprecatedAt time.Time
+	// Sunset is when the version will be removed; zero while none is planned
+	Sunset time.Time
+	// Successor is the prefix of the version replacing a deprecated one
+	Successor string
+}
+
+// Deprecated reports whether clients should move off the version
+func (v APIVersion) Deprecated() bool {
+	return !v.DeprecatedAt.IsZero()
+}
+
+// APIVersionMiddleware creates middleware that tags requests with the API
+// version, counts them per version and, for deprecated versions, sets the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and successor Link headers
+func APIVersionMiddleware(version APIVersion, m *metrics.Metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("api_version", version.Name)
+
+			header := c.Response().Header()
+			if version.Deprecated() {
+				header.Set("Deprecation", "@"+strconv.FormatInt(version.DeprecatedAt.Unix(), 10))
+				if version.Successor != "" {
+					header.Add("Link", "<"+version.Successor+`>; rel="successor-version"`)
+				}
+			}
+			if !version.Sunset.IsZero() {
+				header.Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+			}
+
+			m.RecordAPIVersionRequest(version.Name, version.Deprecated())
+			return next(c)
+		}
+	}
+}
@@ -1,38 +1,63 @@
 package middleware
 
 import (
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
 	"future-star-center-backend/internal/service"
-	"net/http"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
-// AuthMiddleware creates authentication middleware
-func AuthMiddleware(authService service.AuthService) echo.MiddlewareFunc {
+// SessionCookieName is the cookie carrying the session ID for browser clients
+const SessionCookieName = "session_id"
+
+// Sources a request's credentials can come from, stored as "auth_source"
+const (
+	AuthSourceAPIKey = "api_key"
+	AuthSourceHeader = "header"
+	AuthSourceCookie = "cookie"
+	AuthSourceQuery  = "query"
+)
+
+// AuthMiddleware creates authentication middleware. Requests are authenticated
+// either by an API key or by a session.
+func AuthMiddleware(
+	authService service.AuthService,
+	apiKeyService service.APIKeyService,
+	sessionConfig config.SessionConfig,
+) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// API keys take precedence over sessions
+			if rawKey := getAPIKey(c); rawKey != "" {
+				key, err := apiKeyService.Authenticate(c.Request().Context(), rawKey)
+				if err != nil {
+					return err
+				}
+
+				c.Set("api_key", key)
+				c.Set("api_key_id", key.ID.Hex())
+				c.Set("auth_source", AuthSourceAPIKey)
+				return next(c)
+			}
+
 			// Get session ID from header, cookie, or query parameter
-			sessionID := getSessionID(c)
+			sessionID, source := getSessionID(c, sessionConfig)
 			if sessionID == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error":   "unauthorized",
-					"message": "Missing session ID",
-				})
+				return domain.ErrAuthenticationNeeded
 			}
 
 			// Validate session and get user
 			user, err := authService.ValidateSession(c.Request().Context(), sessionID)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error":   "unauthorized",
-					"message": "Invalid session",
-				})
+				return err
 			}
 
 			// Set user and session ID in context
 			c.Set("user", user)
 			c.Set("session_id", sessionID)
+			c.Set("auth_source", source)
 			c.Set("user_id", user.ID.Hex())
 			c.Set("user_role", string(user.Role))
 
@@ -42,15 +67,16 @@ func AuthMiddleware(authService service.AuthService) echo.MiddlewareFunc {
 }
 
 // OptionalAuthMiddleware creates optional authentication middleware
-func OptionalAuthMiddleware(authService service.AuthService) echo.MiddlewareFunc {
+func OptionalAuthMiddleware(authService service.AuthService, sessionConfig config.SessionConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			sessionID := getSessionID(c)
+			sessionID, source := getSessionID(c, sessionConfig)
 			if sessionID != "" {
 				user, err := authService.ValidateSession(c.Request().Context(), sessionID)
 				if err == nil {
 					c.Set("user", user)
 					c.Set("session_id", sessionID)
+					c.Set("auth_source", source)
 					c.Set("user_id", user.ID.Hex())
 					c.Set("user_role", string(user.Role))
 				}
@@ -66,10 +92,7 @@ func RoleMiddleware(allowedRoles ...string) echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			userRole := c.Get("user_role")
 			if userRole == nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error":   "unauthorized",
-					"message": "User not authenticated",
-				})
+				return domain.ErrAuthenticationNeeded
 			}
 
 			role := userRole.(string)
@@ -79,34 +102,96 @@ func RoleMiddleware(allowedRoles ...string) echo.MiddlewareFunc {
 				}
 			}
 
-			return c.JSON(http.StatusForbidden, map[string]string{
-				"error":   "forbidden",
-				"message": "Insufficient permissions",
-			})
+			return domain.ErrInsufficientRole
+		}
+	}
+}
+
+// ScopeMiddleware creates API key scope authorization middleware. Requests
+// authenticated by a session are passed through to role checks.
+func ScopeMiddleware(requiredScopes ...domain.APIKeyScope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ok := c.Get("api_key").(*domain.APIKey)
+			if !ok {
+				return next(c)
+			}
+
+			for _, scope := range requiredScopes {
+				if !key.HasScope(scope) {
+					return domain.ErrInsufficientScope
+				}
+			}
+
+			return next(c)
 		}
 	}
 }
 
-// getSessionID extracts session ID from various sources
-func getSessionID(c echo.Context) string {
+// RoleOrScopeMiddleware creates authorization middleware for routes open to
+// both users and machine clients: sessions need one of the allowed roles, and
+// API keys every required scope
+func RoleOrScopeMiddleware(allowedRoles []string, requiredScopes ...domain.APIKeyScope) echo.MiddlewareFunc {
+	requireRole := RoleMiddleware(allowedRoles...)
+	requireScopes := ScopeMiddleware(requiredScopes...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		byRole, byScopes := requireRole(next), requireScopes(next)
+		return func(c echo.Context) error {
+			if _, ok := c.Get("api_key").(*domain.APIKey); ok {
+				return byScopes(c)
+			}
+			return byRole(c)
+		}
+	}
+}
+
+// getAPIKey extracts an API key from the X-API-Key header, or from a Bearer
+// token carrying the API key prefix
+func getAPIKey(c echo.Context) string {
+	apiKey := c.Request().Header.Get("X-API-Key")
+	if apiKey != "" {
+		return apiKey
+	}
+
+	auth := c.Request().Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok && strings.HasPrefix(token, domain.APIKeyPrefix) {
+		return token
+	}
+
+	return ""
+}
+
+// getSessionID extracts session ID from various sources and reports which
+// source it came from
+func getSessionID(c echo.Context, sessionConfig config.SessionConfig) (string, string) {
 	// 1. Check X-Session-ID header
 	sessionID := c.Request().Header.Get("X-Session-ID")
 	if sessionID != "" {
-		return sessionID
+		return sessionID, AuthSourceHeader
 	}
 
 	// 2. Check Authorization header (Bearer token style)
 	auth := c.Request().Header.Get("Authorization")
 	if auth != "" && strings.HasPrefix(auth, "Bearer ") {
-		return strings.TrimPrefix(auth, "Bearer ")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		// API keys are never session IDs
+		if !strings.HasPrefix(token, domain.APIKeyPrefix) {
+			return token, AuthSourceHeader
+		}
 	}
 
 	// 3. Check session_id cookie
-	cookie, err := c.Cookie("session_id")
+	cookie, err := c.Cookie(SessionCookieName)
 	if err == nil && cookie.Value != "" {
-		return cookie.Value
+		return cookie.Value, AuthSourceCookie
 	}
 
 	// 4. Check query parameter
-	return c.QueryParam("session_id")
+	if sessionConfig.AllowQueryParam {
+		if sessionID := c.QueryParam("session_id"); sessionID != "" {
+			return sessionID, AuthSourceQuery
+		}
+	}
+
+	return "", ""
 }
@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"errors"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRoleOrScopeMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		// authenticate sets what AuthMiddleware would have put in the context
+		authenticate func(c echo.Context)
+		wantErr      error
+	}{
+		{
+			name: "allows a key holding the scope",
+			authenticate: func(c echo.Context) {
+				c.Set("api_key", &domain.APIKey{Scopes: []domain.APIKeyScope{domain.ScopeBillingRead, domain.ScopeUsersRead}})
+			},
+		},
+		{
+			name: "rejects a key missing the scope",
+			authenticate: func(c echo.Context) {
+				c.Set("api_key", &domain.APIKey{Scopes: []domain.APIKeyScope{domain.ScopeBillingRead}})
+			},
+			wantErr: domain.ErrInsufficientScope,
+		},
+		{
+			name: "rejects a key without scopes",
+			authenticate: func(c echo.Context) {
+				c.Set("api_key", &domain.APIKey{})
+			},
+			wantErr: domain.ErrInsufficientScope,
+		},
+		{
+			name: "allows a session with the role",
+			authenticate: func(c echo.Context) {
+				c.Set("user_role", string(domain.RoleAdmin))
+			},
+		},
+		{
+			name: "rejects a session without the role",
+			authenticate: func(c echo.Context) {
+				c.Set("user_role", string(domain.RoleStaff))
+			},
+			wantErr: domain.ErrInsufficientRole,
+		},
+		{
+			name:         "rejects an unauthenticated request",
+			authenticate: func(c echo.Context) {},
+			wantErr:      domain.ErrAuthenticationNeeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			tt.authenticate(c)
+
+			called := false
+			h := middleware.RoleOrScopeMiddleware([]string{string(domain.RoleAdmin)}, domain.ScopeUsersRead)(
+				func(c echo.Context) error {
+					called = true
+					return nil
+				})
+
+			err := h(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if called != (tt.wantErr == nil) {
+				t.Errorf("handler called = %t, want %t", called, tt.wantErr == nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"future-star-center-backend/internal/domain"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// CSRFCookieName is the cookie holding the CSRF token. It is readable by
+	// scripts so the web client can echo it back in CSRFHeaderName.
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName is the header the client must send the CSRF token in
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRFMiddleware creates double-submit CSRF protection middleware. It must run
+// after AuthMiddleware and only checks state-changing requests authenticated
+// by the session cookie; header and API key credentials cannot be sent by a
+// cross-site form, so they need no token.
+func CSRFMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+
+			if c.Get("auth_source") != AuthSourceCookie {
+				return next(c)
+			}
+
+			cookie, err := c.Cookie(CSRFCookieName)
+			header := c.Request().Header.Get(CSRFHeaderName)
+			if err != nil || cookie.Value == "" || header == "" ||
+				subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+				return domain.ErrCSRFTokenInvalid
+			}
+
+			return next(c)
+		}
+	}
+}
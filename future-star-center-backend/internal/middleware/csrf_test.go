@@ -0,0 +1,115 @@
+package middleware_test
+
+import (
+	"errors"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	const token = "csrf-token"
+
+	tests := []struct {
+		name       string
+		method     string
+		authSource string
+		cookie     string
+		header     string
+		wantErr    error
+	}{
+		{
+			name:       "allows a cookie session echoing the token",
+			method:     http.MethodPost,
+			authSource: middleware.AuthSourceCookie,
+			cookie:     token,
+			header:     token,
+		},
+		{
+			name:       "rejects a cookie session without the header",
+			method:     http.MethodPost,
+			authSource: middleware.AuthSourceCookie,
+			cookie:     token,
+			wantErr:    domain.ErrCSRFTokenInvalid,
+		},
+		{
+			name:       "rejects a cookie session without the token cookie",
+			method:     http.MethodDelete,
+			authSource: middleware.AuthSourceCookie,
+			header:     token,
+			wantErr:    domain.ErrCSRFTokenInvalid,
+		},
+		{
+			name:       "rejects a mismatched token",
+			method:     http.MethodPatch,
+			authSource: middleware.AuthSourceCookie,
+			cookie:     token,
+			header:     "another-token",
+			wantErr:    domain.ErrCSRFTokenInvalid,
+		},
+		{
+			name:       "lets GET through without a token",
+			method:     http.MethodGet,
+			authSource: middleware.AuthSourceCookie,
+		},
+		{
+			name:       "lets HEAD through without a token",
+			method:     http.MethodHead,
+			authSource: middleware.AuthSourceCookie,
+		},
+		{
+			name:       "lets OPTIONS through without a token",
+			method:     http.MethodOptions,
+			authSource: middleware.AuthSourceCookie,
+		},
+		{
+			name:       "skips API keys",
+			method:     http.MethodPost,
+			authSource: middleware.AuthSourceAPIKey,
+		},
+		{
+			name:       "skips sessions sent in a header",
+			method:     http.MethodPost,
+			authSource: middleware.AuthSourceHeader,
+		},
+		{
+			name:   "skips unauthenticated requests",
+			method: http.MethodPost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(tt.method, "/admin/users/1", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(middleware.CSRFHeaderName, tt.header)
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+			if tt.authSource != "" {
+				c.Set("auth_source", tt.authSource)
+			}
+
+			called := false
+			h := middleware.CSRFMiddleware()(func(c echo.Context) error {
+				called = true
+				return nil
+			})
+
+			err := h(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if called != (tt.wantErr == nil) {
+				t.Errorf("handler called = %t, want %t", called, tt.wantErr == nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"future-star-center-backend/internal/metrics"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware creates middleware recording request durations per route.
+// It must wrap RequestLoggerMiddleware, which writes the error response, so
+// that the status read once the request is handled is the one sent. An error
+// nothing has written yet is recorded with its HTTP status, or as an internal
+// error.
+func MetricsMiddleware(m *metrics.Metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+			}
+
+			// Unmatched routes share one label to keep cardinality bounded
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			m.ObserveHTTPRequest(c.Request().Method, route, status, time.Since(start))
+			return err
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"errors"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/handler"
+	"future-star-center-backend/internal/metrics"
+	"future-star-center-backend/internal/middleware"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		handler echo.HandlerFunc
+		// withLogger puts the request logger, which writes error responses,
+		// inside the metrics middleware as main does
+		withLogger bool
+		wantRoute  string
+		wantStatus string
+	}{
+		{
+			name:       "success",
+			path:       "/users/65f1c0ffee",
+			handler:    func(c echo.Context) error { return c.NoContent(http.StatusNoContent) },
+			wantRoute:  "/users/:id",
+			wantStatus: "204",
+		},
+		{
+			name:       "error written by the request logger",
+			path:       "/users/65f1c0ffee",
+			handler:    func(c echo.Context) error { return domain.ErrAuthenticationNeeded },
+			withLogger: true,
+			wantRoute:  "/users/:id",
+			wantStatus: "401",
+		},
+		{
+			name:       "unwritten HTTP error",
+			path:       "/users/65f1c0ffee",
+			handler:    func(c echo.Context) error { return echo.NewHTTPError(http.StatusConflict) },
+			wantRoute:  "/users/:id",
+			wantStatus: "409",
+		},
+		{
+			name:       "unwritten error",
+			path:       "/users/65f1c0ffee",
+			handler:    func(c echo.Context) error { return errors.New("boom") },
+			wantRoute:  "/users/:id",
+			wantStatus: "500",
+		},
+		{
+			name:       "unmatched route",
+			path:       "/missing",
+			handler:    func(c echo.Context) error { return c.NoContent(http.StatusNoContent) },
+			withLogger: true,
+			wantRoute:  "unmatched",
+			wantStatus: "404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			m := metrics.New()
+
+			e := echo.New()
+			e.HTTPErrorHandler = handler.NewHTTPErrorHandler(log)
+			e.Use(middleware.MetricsMiddleware(m))
+			if tt.withLogger {
+				e.Use(middleware.RequestLoggerMiddleware(log))
+			}
+			e.GET("/users/:id", tt.handler)
+
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			want := `future_star_http_request_duration_seconds_count{method="GET",route="` +
+				tt.wantRoute + `",status="` + tt.wantStatus + `"} 1`
+			if got := scrape(t, m); !strings.Contains(got, want) {
+				t.Errorf("metrics do not contain %s:\n%s", want, requestDurations(got))
+			}
+		})
+	}
+}
+
+// scrape returns the metrics m exposes, in the text format
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	return rec.Body.String()
+}
+
+// requestDurations keeps the request duration counts of scraped metrics
+func requestDurations(metrics string) string {
+	var lines []string
+	for _, line := range strings.Split(metrics, "\n") {
+		if strings.HasPrefix(line, "future_star_http_request_duration_seconds_count") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
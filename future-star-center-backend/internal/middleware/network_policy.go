@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NetworkPolicyMiddleware creates middleware enforcing the network policy of
+// the authenticated user's role. API keys are issued by admins and reach
+// admin routes, so they are held to the admin policy. It must run after
+// AuthMiddleware; the client IP comes from Echo's IP extractor, which only
+// trusts configured proxies.
+func NetworkPolicyMiddleware(policyService service.NetworkPolicyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var role domain.UserRole
+			var actorID string
+			if userRole, ok := c.Get("user_role").(string); ok {
+				role = domain.UserRole(userRole)
+				actorID, _ = c.Get("user_id").(string)
+			} else if c.Get("auth_source") == AuthSourceAPIKey {
+				role = domain.RoleAdmin
+				actorID, _ = c.Get("api_key_id").(string)
+			} else {
+				return next(c)
+			}
+
+			err := policyService.Authorize(c.Request().Context(), role, c.RealIP(), actorID, c.Path())
+			if err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// NewIPExtractor returns an IP extractor that only honours X-Forwarded-For
+// when the request comes from one of the trusted proxies, so clients cannot
+// spoof their address
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	trustOptions := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", cidr, err)
+		}
+		trustOptions = append(trustOptions, echo.TrustIPRange(network))
+	}
+
+	return echo.ExtractIPFromXFFHeader(trustOptions...), nil
+}
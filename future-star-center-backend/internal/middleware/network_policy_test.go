@@ -0,0 +1,190 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/middleware"
+	"future-star-center-backend/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeNetworkPolicies enforces fixed policies and records what it was asked
+type fakeNetworkPolicies struct {
+	policies map[domain.UserRole]*domain.NetworkPolicy
+	role     domain.UserRole
+	ip       string
+	actorID  string
+}
+
+func (f *fakeNetworkPolicies) Authorize(ctx context.Context, role domain.UserRole, ipAddress, actorID, path string) error {
+	f.role, f.ip, f.actorID = role, ipAddress, actorID
+	if policy, ok := f.policies[role]; ok && !policy.Allows(ipAddress) {
+		return domain.ErrNetworkNotAllowed
+	}
+	return nil
+}
+
+func (f *fakeNetworkPolicies) List(ctx context.Context) ([]*domain.NetworkPolicy, error) {
+	return nil, nil
+}
+
+func (f *fakeNetworkPolicies) Update(ctx context.Context, role domain.UserRole, req service.UpdateNetworkPolicyRequest, updatedBy string) (*domain.NetworkPolicy, error) {
+	return nil, nil
+}
+
+func TestNetworkPolicyMiddleware(t *testing.T) {
+	const (
+		officeIP = "198.51.100.7"
+		homeIP   = "203.0.113.9"
+		proxyIP  = "10.0.0.5"
+	)
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		// authenticate sets what AuthMiddleware would have put in the context;
+		// the wanted role, IP and actor are those the policy is checked for
+		authenticate func(c echo.Context)
+		remoteAddr   string
+		forwardedFor string
+		wantErr      error
+		wantRole     domain.UserRole
+		wantIP       string
+		wantActorID  string
+	}{
+		{
+			name:         "allows an admin session from the office",
+			authenticate: adminSession,
+			remoteAddr:   officeIP,
+			wantRole:     domain.RoleAdmin,
+			wantIP:       officeIP,
+			wantActorID:  "admin-id",
+		},
+		{
+			name:         "blocks an admin session from elsewhere",
+			authenticate: adminSession,
+			remoteAddr:   homeIP,
+			wantErr:      domain.ErrNetworkNotAllowed,
+			wantRole:     domain.RoleAdmin,
+			wantIP:       homeIP,
+			wantActorID:  "admin-id",
+		},
+		{
+			name: "holds API keys to the admin policy",
+			authenticate: func(c echo.Context) {
+				c.Set("api_key", &domain.APIKey{})
+				c.Set("api_key_id", "key-id")
+				c.Set("auth_source", middleware.AuthSourceAPIKey)
+			},
+			remoteAddr:  homeIP,
+			wantErr:     domain.ErrNetworkNotAllowed,
+			wantRole:    domain.RoleAdmin,
+			wantIP:      homeIP,
+			wantActorID: "key-id",
+		},
+		{
+			name:         "skips unauthenticated requests",
+			authenticate: func(c echo.Context) {},
+			remoteAddr:   homeIP,
+		},
+		{
+			name:         "ignores X-Forwarded-For without trusted proxies",
+			authenticate: adminSession,
+			remoteAddr:   homeIP,
+			forwardedFor: officeIP,
+			wantErr:      domain.ErrNetworkNotAllowed,
+			wantRole:     domain.RoleAdmin,
+			wantIP:       homeIP,
+			wantActorID:  "admin-id",
+		},
+		{
+			name:           "ignores X-Forwarded-For from an untrusted peer",
+			trustedProxies: []string{"10.0.0.0/8"},
+			authenticate:   adminSession,
+			remoteAddr:     homeIP,
+			forwardedFor:   officeIP,
+			wantErr:        domain.ErrNetworkNotAllowed,
+			wantRole:       domain.RoleAdmin,
+			wantIP:         homeIP,
+			wantActorID:    "admin-id",
+		},
+		{
+			name:           "uses X-Forwarded-For from a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			authenticate:   adminSession,
+			remoteAddr:     proxyIP,
+			forwardedFor:   officeIP,
+			wantRole:       domain.RoleAdmin,
+			wantIP:         officeIP,
+			wantActorID:    "admin-id",
+		},
+		{
+			name:           "ignores addresses the client prepended before a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			authenticate:   adminSession,
+			remoteAddr:     proxyIP,
+			forwardedFor:   officeIP + ", " + homeIP,
+			wantErr:        domain.ErrNetworkNotAllowed,
+			wantRole:       domain.RoleAdmin,
+			wantIP:         homeIP,
+			wantActorID:    "admin-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := &fakeNetworkPolicies{policies: map[domain.UserRole]*domain.NetworkPolicy{
+				domain.RoleAdmin: {Role: domain.RoleAdmin, Allow: []string{"198.51.100.0/24"}},
+			}}
+
+			e := echo.New()
+			extractor, err := middleware.NewIPExtractor(tt.trustedProxies)
+			if err != nil {
+				t.Fatalf("NewIPExtractor() error = %v", err)
+			}
+			e.IPExtractor = extractor
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/users/1", nil)
+			req.RemoteAddr = tt.remoteAddr + ":41000"
+			if tt.forwardedFor != "" {
+				req.Header.Set(echo.HeaderXForwardedFor, tt.forwardedFor)
+			}
+			c := e.NewContext(req, httptest.NewRecorder())
+			tt.authenticate(c)
+
+			called := false
+			h := middleware.NetworkPolicyMiddleware(policies)(func(c echo.Context) error {
+				called = true
+				return nil
+			})
+
+			err = h(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if called != (tt.wantErr == nil) {
+				t.Errorf("handler called = %t, want %t", called, tt.wantErr == nil)
+			}
+			if policies.role != tt.wantRole || policies.ip != tt.wantIP || policies.actorID != tt.wantActorID {
+				t.Errorf("checked role %q, IP %q, actor %q, want %q, %q, %q",
+					policies.role, policies.ip, policies.actorID, tt.wantRole, tt.wantIP, tt.wantActorID)
+			}
+		})
+	}
+}
+
+func TestNewIPExtractorRejectsInvalidCIDR(t *testing.T) {
+	if _, err := middleware.NewIPExtractor([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("NewIPExtractor() error = nil, want an invalid CIDR error")
+	}
+}
+
+func adminSession(c echo.Context) {
+	c.Set("user_id", "admin-id")
+	c.Set("user_role", string(domain.RoleAdmin))
+}
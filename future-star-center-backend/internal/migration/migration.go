@@ -0,0 +1,293 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// migrationsCollection records the applied migrations
+	migrationsCollection = "schema_migrations"
+	// locksCollection holds the lock taken while migrations run
+	locksCollection = "schema_migration_locks"
+	lockID          = "schema_migrations"
+	// lockLease is how long a lock is held before another replica may take it
+	// over, in case the holder crashed without releasing it. The holder
+	// renews it every lockRenewInterval for as long as its migrations run.
+	lockLease         = time.Minute
+	lockRenewInterval = lockLease / 3
+	// lockRetryInterval is how often a waiting replica retries the lock
+	lockRetryInterval = 2 * time.Second
+)
+
+// Migration is a versioned change to the database schema. Versions are
+// applied in ascending order and must never be reused or renumbered once
+// released.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// record is the schema_migrations document of an applied migration
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// errLockLost is the cause of cancelling migrations whose lock could not be
+// renewed
+var errLockLost = errors.New("migration lock lost")
+
+// Migrator applies and rolls back migrations, holding a lock in MongoDB so
+// that replicas starting together do not run them concurrently
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	owner      string
+	logger     *slog.Logger
+	clock      clock.Clock
+}
+
+// New creates a migrator for the given migrations, which are sorted by version
+func New(db *mongo.Database, migrations []Migration, logger *slog.Logger, clock clock.Clock) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 || m.Up == nil {
+			return nil, fmt.Errorf("migration %d (%s) needs a positive version and an Up function", m.Version, m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		owner:      hostname + "/" + uuid.New().String(),
+		logger:     logger,
+		clock:      clock,
+	}, nil
+}
+
+// Up applies all pending migrations, returning how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			m.logger.InfoContext(ctx, "applying migration", "version", migration.Version, "name", migration.Name)
+			if err := migration.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err := m.db.Collection(migrationsCollection).InsertOne(ctx, record{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: m.clock.Now(),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migrations, up to steps of them,
+// returning how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(ctx context.Context) error {
+		done, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Name)
+			}
+
+			m.logger.InfoContext(ctx, "rolling back migration", "version", migration.Version, "name", migration.Name)
+			if err := migration.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+
+			_, err := m.db.Collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": migration.Version})
+			if err != nil {
+				return fmt.Errorf("failed to remove migration record %d: %w", migration.Version, err)
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := done[migration.Version]; ok {
+			status.AppliedAt = &r.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// applied returns the recorded migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.db.Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	done := make(map[int]record, len(records))
+	for _, r := range records {
+		done[r.Version] = r
+	}
+	return done, nil
+}
+
+// withLock runs fn while holding the migration lock, waiting for another
+// replica to finish first if needed. The context passed to fn is cancelled
+// if the lock cannot be renewed, before another replica may take it over.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	for {
+		acquired, err := m.acquireLock(ctx)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+
+		m.logger.InfoContext(ctx, "waiting for migration lock held by another instance")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for migration lock: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	defer func() {
+		// Release even if ctx was cancelled, otherwise others wait a full lease
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := m.db.Collection(locksCollection).DeleteOne(releaseCtx, bson.M{"_id": lockID, "owner": m.owner})
+		if err != nil {
+			m.logger.Error("failed to release migration lock", "error", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go m.renewLock(ctx, cancel)
+
+	if err := fn(ctx); err != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errLockLost) {
+			return fmt.Errorf("%w: %w", cause, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// renewLock extends the lock's lease until ctx is done, cancelling ctx if
+// the lock is no longer held
+func (m *Migrator) renewLock(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		filter := bson.M{"_id": lockID, "owner": m.owner}
+		update := bson.M{"$set": bson.M{"expires_at": m.clock.Now().Add(lockLease)}}
+		result, err := m.db.Collection(locksCollection).UpdateOne(ctx, filter, update)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			m.logger.ErrorContext(ctx, "failed to renew migration lock", "error", err)
+			cancel(fmt.Errorf("%w: %w", errLockLost, err))
+			return
+		case result.MatchedCount == 0:
+			m.logger.ErrorContext(ctx, "migration lock was taken over by another instance")
+			cancel(errLockLost)
+			return
+		}
+	}
+}
+
+// acquireLock takes the lock if it is free or its lease has expired
+func (m *Migrator) acquireLock(ctx context.Context) (bool, error) {
+	now := m.clock.Now()
+	filter := bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "expires_at": now.Add(lockLease)}}
+
+	_, err := m.db.Collection(locksCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// The upsert collides with an unexpired lock held by someone else
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return true, nil
+}
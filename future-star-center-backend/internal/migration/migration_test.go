@@ -0,0 +1,284 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoURIEnv is the MongoDB connection string, as for the repository
+// contract tests. Every test creates and drops its own database.
+const mongoURIEnv = "TEST_MONGODB_URI"
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestMigratorUpSkipsAppliedVersions(t *testing.T) {
+	ctx := context.Background()
+	db := newMongoDatabase(t)
+	clock := clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC))
+
+	runs := map[int]int{}
+	migration := func(version int) Migration {
+		return Migration{
+			Version: version,
+			Name:    "test",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				runs[version]++
+				return nil
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				runs[version]--
+				return nil
+			},
+		}
+	}
+
+	first := newMigrator(t, db, []Migration{migration(2), migration(1)}, clock)
+	if applied, err := first.Up(ctx); err != nil || applied != 2 {
+		t.Fatalf("Up() = %d, %v, want 2, nil", applied, err)
+	}
+
+	// A release adding migration 3 only applies that one
+	clock.Advance(time.Hour)
+	second := newMigrator(t, db, []Migration{migration(1), migration(2), migration(3)}, clock)
+	if applied, err := second.Up(ctx); err != nil || applied != 1 {
+		t.Fatalf("Up() = %d, %v, want 1, nil", applied, err)
+	}
+	if applied, err := second.Up(ctx); err != nil || applied != 0 {
+		t.Fatalf("Up() again = %d, %v, want 0, nil", applied, err)
+	}
+	if runs[1] != 1 || runs[2] != 1 || runs[3] != 1 {
+		t.Errorf("runs = %v, want each migration applied once", runs)
+	}
+
+	statuses, err := second.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for _, status := range statuses {
+		want := clock.Now().Add(-time.Hour)
+		if status.Version == 3 {
+			want = clock.Now()
+		}
+		if status.AppliedAt == nil || !status.AppliedAt.Equal(want) {
+			t.Errorf("migration %d applied at %v, want %v", status.Version, status.AppliedAt, want)
+		}
+	}
+
+	if rolledBack, err := second.Down(ctx, 2); err != nil || rolledBack != 2 {
+		t.Fatalf("Down(2) = %d, %v, want 2, nil", rolledBack, err)
+	}
+	if runs[1] != 1 || runs[2] != 0 || runs[3] != 0 {
+		t.Errorf("runs after Down(2) = %v, want migrations 3 and 2 rolled back", runs)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	ctx := context.Background()
+	db := newMongoDatabase(t)
+	clock := clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC))
+
+	// holder applies a migration that runs until released
+	started := make(chan struct{})
+	release := make(chan struct{})
+	holder := newMigrator(t, db, []Migration{{
+		Version: 1,
+		Name:    "slow",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}}, clock)
+	holderDone := make(chan error, 1)
+	go func() {
+		_, err := holder.Up(ctx)
+		holderDone <- err
+	}()
+	<-started
+
+	ran := false
+	waiter := newMigrator(t, db, []Migration{{
+		Version: 2,
+		Name:    "quick",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			ran = true
+			return nil
+		},
+	}}, clock)
+
+	t.Run("waits while the lock is held", func(t *testing.T) {
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := waiter.Up(waitCtx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Up() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if ran {
+			t.Error("migration ran while another instance held the lock")
+		}
+	})
+
+	t.Run("takes over a lease that was not renewed", func(t *testing.T) {
+		clock.Advance(lockLease + time.Second)
+
+		if applied, err := waiter.Up(ctx); err != nil || applied != 1 {
+			t.Errorf("Up() = %d, %v, want 1, nil", applied, err)
+		}
+	})
+
+	close(release)
+	if err := <-holderDone; err != nil {
+		t.Fatalf("holder Up() error = %v", err)
+	}
+
+	// Both have released the lock
+	count, err := db.Collection(locksCollection).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("CountDocuments() error = %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d locks left, want none", count)
+	}
+}
+
+func TestUsersUniqueIndexesLiveOnly(t *testing.T) {
+	ctx := context.Background()
+	db := newMongoDatabase(t)
+	migrator := newMigrator(t, db, All, clock.Real())
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	names := indexNames(t, db.Collection("users"))
+	for _, name := range []string{*liveEmailIndex.Options.Name, *liveIdentityIndex.Options.Name} {
+		if !slices.Contains(names, name) {
+			t.Errorf("indexes = %v, want %s", names, name)
+		}
+	}
+	for _, name := range []string{"email_1", "external_identities.provider_1_external_identities.subject_1"} {
+		if slices.Contains(names, name) {
+			t.Errorf("indexes = %v, want %s dropped", names, name)
+		}
+	}
+
+	// A deleted user no longer holds on to its email, a live one does
+	users := db.Collection("users")
+	deletedAt := time.Now()
+	documents := []bson.M{
+		{"email": "ayu@example.com", "deleted_at": deletedAt},
+		{"email": "ayu@example.com", "deleted_at": nil},
+	}
+	for _, document := range documents {
+		if _, err := users.InsertOne(ctx, document); err != nil {
+			t.Fatalf("InsertOne(%v) error = %v", document, err)
+		}
+	}
+	_, err := users.InsertOne(ctx, bson.M{"email": "ayu@example.com", "deleted_at": nil})
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("InsertOne(second live user) error = %v, want a duplicate key error", err)
+	}
+
+	// Running the replacement again, as when retrying a failed run, is a no-op
+	for _, migration := range All {
+		if migration.Version != 9 {
+			continue
+		}
+		if err := migration.Up(ctx, db); err != nil {
+			t.Errorf("migration 9 Up() again error = %v", err)
+		}
+	}
+}
+
+func TestReplaceIndexKeepsOldIndexOnFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newMongoDatabase(t)
+	users := db.Collection("users")
+
+	if err := All[0].Up(ctx, db); err != nil {
+		t.Fatalf("creating email_1 error = %v", err)
+	}
+	for _, email := range []string{"ayu@example.com", "budi@example.com"} {
+		if _, err := users.InsertOne(ctx, bson.M{"email": email, "first_name": "Ayu"}); err != nil {
+			t.Fatalf("InsertOne() error = %v", err)
+		}
+	}
+
+	// The replacement cannot be built over the existing users
+	replace := replaceIndex("users", "email_1", mongo.IndexModel{
+		Keys:    bson.D{{Key: "first_name", Value: 1}},
+		Options: options.Index().SetName("first_name_1").SetUnique(true),
+	})
+	if err := replace(ctx, db); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("replaceIndex() error = %v, want a duplicate key error", err)
+	}
+
+	if names := indexNames(t, users); !slices.Contains(names, "email_1") {
+		t.Errorf("indexes = %v, want email_1 kept", names)
+	}
+}
+
+func newMigrator(t *testing.T, db *mongo.Database, migrations []Migration, clock clock.Clock) *Migrator {
+	t.Helper()
+
+	migrator, err := New(db, migrations, discardLogger, clock)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return migrator
+}
+
+// newMongoDatabase creates an empty database that is dropped when the test
+// ends, skipping the test when no MongoDB server is configured
+func newMongoDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv(mongoURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", mongoURIEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("failed to ping MongoDB: %v", err)
+	}
+
+	db := client.Database("future_star_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { db.Drop(context.Background()) })
+	return db
+}
+
+func indexNames(t *testing.T, collection *mongo.Collection) []string {
+	t.Helper()
+
+	specs, err := collection.Indexes().ListSpecifications(context.Background())
+	if err != nil {
+		t.Fatalf("ListSpecifications() error = %v", err)
+	}
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	return names
+}
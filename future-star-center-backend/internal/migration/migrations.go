@@ -0,0 +1,220 @@
+package migration
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All lists the application's migrations. Append new migrations with the
+// next version; never edit one that has been released.
+//
+// Indexes keep MongoDB's default names so that databases created before
+// migrations existed, whose indexes were built at startup, migrate cleanly.
+// Only an index replacing one with the same keys needs a name of its own.
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "users_email_unique",
+		Up: createIndex("users", mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+		Down: dropIndex("users", "email_1"),
+	},
+	{
+		Version: 2,
+		Name:    "users_external_identity_unique",
+		Up: createIndex("users", mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "external_identities.provider", Value: 1},
+				{Key: "external_identities.subject", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"external_identities": bson.M{"$exists": true}}),
+		}),
+		Down: dropIndex("users", "external_identities.provider_1_external_identities.subject_1"),
+	},
+	{
+		Version: 3,
+		Name:    "api_keys_prefix_unique",
+		Up: createIndex("api_keys", mongo.IndexModel{
+			Keys:    bson.D{{Key: "prefix", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}),
+		Down: dropIndex("api_keys", "prefix_1"),
+	},
+	{
+		Version: 4,
+		Name:    "login_events_user_recent",
+		Up: createIndex("login_events", mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		}),
+		Down: dropIndex("login_events", "user_id_1_created_at_-1"),
+	},
+	{
+		Version: 5,
+		Name:    "sessions_expires_at_ttl",
+		Up: createIndex("sessions", mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}),
+		Down: dropIndex("sessions", "expires_at_1"),
+	},
+	{
+		Version: 6,
+		Name:    "sessions_user_id",
+		Up: createIndex("sessions", mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		}),
+		Down: dropIndex("sessions", "user_id_1"),
+	},
+	{
+		Version: 7,
+		Name:    "users_version",
+		Up:      setMissingField("users", "version", int64(0)),
+		Down:    unsetField("users", "version"),
+	},
+	{
+		// Live users store an explicit null, which the partial indexes of
+		// migration 9 select on
+		Version: 8,
+		Name:    "users_deleted_at",
+		Up:      setMissingField("users", "deleted_at", nil),
+		Down:    unsetField("users", "deleted_at"),
+	},
+	{
+		// Deleted users no longer hold on to their email and identities.
+		// The partial indexes are built under new names before the old ones
+		// are dropped, so uniqueness is enforced throughout. Rolling back
+		// fails once two deleted users share an email, as purged users all do.
+		Version: 9,
+		Name:    "users_unique_indexes_live_only",
+		Up: steps(
+			replaceIndex("users", "email_1", liveEmailIndex),
+			replaceIndex("users", "external_identities.provider_1_external_identities.subject_1", liveIdentityIndex),
+		),
+		Down: steps(
+			replaceIndex("users", *liveEmailIndex.Options.Name, mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			}),
+			replaceIndex("users", *liveIdentityIndex.Options.Name, mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "external_identities.provider", Value: 1},
+					{Key: "external_identities.subject", Value: 1},
+				},
+				Options: options.Index().
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"external_identities": bson.M{"$exists": true}}),
+			}),
+		),
+	},
+}
+
+// liveEmailIndex and liveIdentityIndex only cover users that have not been
+// deleted. They share the keys of the indexes they replace, which MongoDB
+// 5.0 and later allow for indexes with a different partial filter.
+var (
+	liveEmailIndex = mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().
+			SetName("email_1_live").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$type": "null"}}),
+	}
+	liveIdentityIndex = mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "external_identities.provider", Value: 1},
+			{Key: "external_identities.subject", Value: 1},
+		},
+		Options: options.Index().
+			SetName("external_identities_live").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{
+				"external_identities": bson.M{"$exists": true},
+				"deleted_at":          bson.M{"$type": "null"},
+			}),
+	}
+)
+
+// createIndex returns a migration step creating an index, which is a no-op
+// when an identical index already exists
+func createIndex(collection string, model mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateOne(ctx, model)
+		return err
+	}
+}
+
+// dropIndex returns a migration step dropping an index by name
+func dropIndex(collection, name string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+		return err
+	}
+}
+
+// indexNotFoundCode is the MongoDB error code of dropping a missing index
+const indexNotFoundCode = 27
+
+// replaceIndex returns a migration step replacing the index with the given
+// name by model, which must have a different name. The new index is created
+// before the old one is dropped, so a unique constraint never lapses, and
+// both halves are no-ops when already done, so a failed step can be retried.
+func replaceIndex(collection, name string, model mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if err := createIndex(collection, model)(ctx, db); err != nil {
+			return err
+		}
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode) {
+			return err
+		}
+		return nil
+	}
+}
+
+// steps returns a migration step running the given steps in order
+func steps(fns ...func(context.Context, *mongo.Database) error) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, fn := range fns {
+			if err := fn(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// setMissingField returns a migration step setting a field on the documents
+// of a collection that lack it
+func setMissingField(collection, field string, value interface{}) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: value}},
+		)
+		return err
+	}
+}
+
+// unsetField returns a migration step removing a field from every document
+// of a collection
+func unsetField(collection, field string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			bson.M{field: bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{field: ""}},
+		)
+		return err
+	}
+}
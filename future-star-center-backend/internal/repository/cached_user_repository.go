@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/metrics"
+	"log/slog"
+	"time"
+)
+
+type cachedUserRepository struct {
+	next    UserRepository
+	cache   UserCache
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+}
+
+// NewCachedUserRepository wraps a user repository with a read-through cache
+// for GetByID, the lookup behind every authenticated request. Writes go to
+// next and then evict the user from the cache.
+//
+// A lookup racing a write can put the old user back in the cache, so cached
+// users may lag by up to the cache's TTL; keep it short. Cache failures are
+// logged and fall back to next.
+func NewCachedUserRepository(next UserRepository, cache UserCache, logger *slog.Logger, metrics *metrics.Metrics) UserRepository {
+	return &cachedUserRepository{
+		next:    next,
+		cache:   cache,
+		logger:  logger,
+		metrics: metrics,
+	}
+}
+
+func (r *cachedUserRepository) Create(ctx context.Context, user *domain.User) error {
+	return r.next.Create(ctx, user)
+}
+
+func (r *cachedUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := r.cache.Get(ctx, id)
+	switch {
+	case err != nil:
+		r.metrics.RecordUserCacheLookup(metrics.CacheError)
+		r.logger.WarnContext(ctx, "failed to read user cache", "user_id", id, "error", err)
+	case user != nil:
+		r.metrics.RecordUserCacheLookup(metrics.CacheHit)
+		return user, nil
+	default:
+		r.metrics.RecordUserCacheLookup(metrics.CacheMiss)
+	}
+
+	user, err = r.next.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.cache.Set(ctx, user); err != nil {
+		r.logger.WarnContext(ctx, "failed to write user cache", "user_id", id, "error", err)
+	}
+	return user, nil
+}
+
+// GetCurrentByID skips the cache, which may hold an older version
+func (r *cachedUserRepository) GetCurrentByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.next.GetCurrentByID(ctx, id)
+}
+
+func (r *cachedUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return r.next.GetByEmail(ctx, email)
+}
+
+func (r *cachedUserRepository) Update(ctx context.Context, user *domain.User) error {
+	if err := r.next.Update(ctx, user); err != nil {
+		return err
+	}
+	r.evict(ctx, user.ID.Hex())
+	return nil
+}
+
+func (r *cachedUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	if err := r.next.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	r.evict(ctx, id)
+	return nil
+}
+
+func (r *cachedUserRepository) UpdateLastLogin(ctx context.Context, id string) error {
+	if err := r.next.UpdateLastLogin(ctx, id); err != nil {
+		return err
+	}
+	r.evict(ctx, id)
+	return nil
+}
+
+func (r *cachedUserRepository) SetPasswordResetToken(ctx context.Context, email, token string, expiry int64) error {
+	if err := r.next.SetPasswordResetToken(ctx, email, token, expiry); err != nil {
+		return err
+	}
+
+	// The user is only known by email here
+	user, err := r.next.GetByEmail(ctx, email)
+	if err != nil {
+		r.logger.WarnContext(ctx, "failed to find user to evict from cache", "error", err)
+		return nil
+	}
+	r.evict(ctx, user.ID.Hex())
+	return nil
+}
+
+func (r *cachedUserRepository) GetByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	return r.next.GetByPasswordResetToken(ctx, token)
+}
+
+func (r *cachedUserRepository) ClearPasswordResetToken(ctx context.Context, id string) error {
+	if err := r.next.ClearPasswordResetToken(ctx, id); err != nil {
+		return err
+	}
+	r.evict(ctx, id)
+	return nil
+}
+
+func (r *cachedUserRepository) GetByExternalIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	return r.next.GetByExternalIdentity(ctx, provider, subject)
+}
+
+func (r *cachedUserRepository) LinkExternalIdentity(ctx context.Context, id string, identity domain.ExternalIdentity) error {
+	if err := r.next.LinkExternalIdentity(ctx, id, identity); err != nil {
+		return err
+	}
+	r.evict(ctx, id)
+	return nil
+}
+
+func (r *cachedUserRepository) Restore(ctx context.Context, id string) error {
+	if err := r.next.Restore(ctx, id); err != nil {
+		return err
+	}
+	r.evict(ctx, id)
+	return nil
+}
+
+func (r *cachedUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	return r.next.ListDeletedBefore(ctx, cutoff)
+}
+
+// PurgeDeletedBefore needs no eviction: purged users were deleted, which
+// already evicted them
+func (r *cachedUserRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.next.PurgeDeletedBefore(ctx, cutoff)
+}
+
+// evict removes a user from the cache after a successful write. A failure
+// does not fail the write, which has already happened; the stale copy
+// expires with the cache's TTL.
+func (r *cachedUserRepository) evict(ctx context.Context, id string) {
+	if err := r.cache.Delete(ctx, id); err != nil {
+		r.logger.WarnContext(ctx, "failed to evict user from cache", "user_id", id, "error", err)
+	}
+}
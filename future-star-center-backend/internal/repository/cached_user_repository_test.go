@@ -0,0 +1,154 @@
+package repository_test
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+const cacheTTL = 30 * time.Second
+
+// countingUserRepository counts the GetByID calls reaching the repository
+// behind the cache
+type countingUserRepository struct {
+	repository.UserRepository
+	lookups int
+}
+
+func (r *countingUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	r.lookups++
+	return r.UserRepository.GetByID(ctx, id)
+}
+
+func newCachedUserRepository(t *testing.T, size int) (repository.UserRepository, *countingUserRepository, *clock.Fake) {
+	t.Helper()
+
+	clock := clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC))
+	backing := &countingUserRepository{UserRepository: repository.NewMemoryUserRepository(clock)}
+	cache := repository.NewLRUUserCache(size, cacheTTL, clock)
+	return repository.NewCachedUserRepository(backing, cache, discardLogger, nil), backing, clock
+}
+
+func createUser(t *testing.T, repo repository.UserRepository, email string) string {
+	t.Helper()
+
+	user := &domain.User{Email: email, FirstName: "Ayu", LastName: "Lestari", Role: domain.RoleStaff}
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create(%s) error = %v", email, err)
+	}
+	return user.ID.Hex()
+}
+
+func TestCachedUserRepositoryGetByID(t *testing.T) {
+	tests := []struct {
+		name string
+		// between runs between the first and the second lookup of the user
+		between     func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string)
+		wantLookups int
+	}{
+		{
+			name:        "serves the second lookup from the cache",
+			between:     func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {},
+			wantLookups: 1,
+		},
+		{
+			name: "serves a user until the TTL passes",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				clock.Advance(cacheTTL - time.Second)
+			},
+			wantLookups: 1,
+		},
+		{
+			name: "reloads a user once the TTL passes",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				clock.Advance(cacheTTL)
+			},
+			wantLookups: 2,
+		},
+		{
+			name: "reloads a user after an update",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				user, _ := repo.GetByEmail(context.Background(), "ayu@example.com")
+				user.IsActive = false
+				if err := repo.Update(context.Background(), user); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			},
+			wantLookups: 2,
+		},
+		{
+			name: "reloads a user after a login",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				if err := repo.UpdateLastLogin(context.Background(), id); err != nil {
+					t.Fatalf("UpdateLastLogin() error = %v", err)
+				}
+			},
+			wantLookups: 2,
+		},
+		{
+			name: "reloads a user after a reset token is set",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				expiry := clock.Now().Add(time.Hour).Unix()
+				if err := repo.SetPasswordResetToken(context.Background(), "ayu@example.com", "token", expiry); err != nil {
+					t.Fatalf("SetPasswordResetToken() error = %v", err)
+				}
+			},
+			wantLookups: 2,
+		},
+		{
+			name: "evicts the least recently used user when full",
+			between: func(t *testing.T, repo repository.UserRepository, clock *clock.Fake, id string) {
+				for _, email := range []string{"budi@example.com", "citra@example.com"} {
+					if _, err := repo.GetByID(context.Background(), createUser(t, repo, email)); err != nil {
+						t.Fatalf("GetByID(%s) error = %v", email, err)
+					}
+				}
+			},
+			// Two lookups of the first user plus one of each other user
+			wantLookups: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, backing, clock := newCachedUserRepository(t, 2)
+			id := createUser(t, repo, "ayu@example.com")
+
+			first, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			tt.between(t, repo, clock, id)
+
+			second, err := repo.GetByID(context.Background(), id)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if second.ID != first.ID {
+				t.Errorf("GetByID() = %s, want %s", second.ID.Hex(), first.ID.Hex())
+			}
+			if backing.lookups != tt.wantLookups {
+				t.Errorf("backing repository looked up %d times, want %d", backing.lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestCachedUserRepositoryReturnsCopies(t *testing.T) {
+	repo, _, _ := newCachedUserRepository(t, 2)
+	id := createUser(t, repo, "ayu@example.com")
+
+	user, _ := repo.GetByID(context.Background(), id)
+	user.IsActive = false
+
+	cached, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if !cached.IsActive {
+		t.Error("changing a returned user changed the cached one")
+	}
+}
@@ -0,0 +1,150 @@
+package repository_test
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/migration"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/repository/repositorytest"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The MongoDB and Redis contract tests run only when these variables point at
+// servers the tests may write to
+const (
+	// mongoURIEnv is the MongoDB connection string. Every test creates and
+	// drops its own database.
+	mongoURIEnv = "TEST_MONGODB_URI"
+	// redisAddrEnv is the Redis address. The database chosen by redisDBEnv,
+	// 15 by default, is flushed before every test.
+	redisAddrEnv = "TEST_REDIS_ADDR"
+	redisDBEnv   = "TEST_REDIS_DB"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestMemoryUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T, clock clock.Clock) repository.UserRepository {
+		return repository.NewMemoryUserRepository(clock)
+	})
+}
+
+func TestMemorySessionRepository(t *testing.T) {
+	repositorytest.TestSessionRepository(t, func(t *testing.T, clock clock.Clock) repository.SessionRepository {
+		return repository.NewMemorySessionRepository(clock)
+	})
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T, clock clock.Clock) repository.UserRepository {
+		cache := repository.NewLRUUserCache(100, time.Minute, clock)
+		return repository.NewCachedUserRepository(repository.NewMemoryUserRepository(clock), cache, discardLogger, nil)
+	})
+}
+
+func TestMongoUserRepository(t *testing.T) {
+	client := connectMongoDB(t)
+
+	repositorytest.TestUserRepository(t, func(t *testing.T, clock clock.Clock) repository.UserRepository {
+		return repository.NewMongoUserRepository(newMongoDatabase(t, client), clock)
+	})
+}
+
+func TestMongoSessionRepository(t *testing.T) {
+	client := connectMongoDB(t)
+
+	repositorytest.TestSessionRepository(t, func(t *testing.T, clock clock.Clock) repository.SessionRepository {
+		return repository.NewMongoSessionRepository(newMongoDatabase(t, client), clock)
+	})
+}
+
+func TestRedisSessionRepository(t *testing.T) {
+	client := connectRedis(t)
+
+	repositorytest.TestSessionRepository(t, func(t *testing.T, clock clock.Clock) repository.SessionRepository {
+		if err := client.FlushDB(context.Background()).Err(); err != nil {
+			t.Fatalf("failed to flush Redis: %v", err)
+		}
+		return repository.NewRedisSessionRepository(client, discardLogger, clock)
+	})
+}
+
+// connectMongoDB connects to the test MongoDB server, skipping the test when
+// none is configured
+func connectMongoDB(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	uri := os.Getenv(mongoURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", mongoURIEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("failed to ping MongoDB: %v", err)
+	}
+	return client
+}
+
+// newMongoDatabase creates a migrated database that is dropped when the test
+// ends
+func newMongoDatabase(t *testing.T, client *mongo.Client) *mongo.Database {
+	t.Helper()
+
+	db := client.Database("future_star_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { db.Drop(context.Background()) })
+
+	migrator, err := migration.New(db, migration.All, discardLogger, clock.Real())
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+// connectRedis connects to the test Redis server, skipping the test when
+// none is configured
+func connectRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv(redisAddrEnv)
+	if addr == "" {
+		t.Skipf("%s is not set", redisAddrEnv)
+	}
+
+	db := 15
+	if value := os.Getenv(redisDBEnv); value != "" {
+		var err error
+		if db, err = strconv.Atoi(value); err != nil {
+			t.Fatalf("%s: %v", redisDBEnv, err)
+		}
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: db})
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to ping Redis: %v", err)
+	}
+	return client
+}
@@ -3,19 +3,38 @@ package repository
 import (
 	"context"
 	"future-star-center-backend/internal/domain"
+	"time"
 )
 
-// UserRepository defines the interface for user data access
+// UserRepository defines the interface for user data access. Delete only
+// marks a user as deleted: deleted users are hidden from every other method
+// until Restore, or for good once PurgeDeletedBefore anonymises them.
 type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
+	// GetByID reads the user without its password hash or reset token, so
+	// that it can be cached; do not pass the result to Update
 	GetByID(ctx context.Context, id string) (*domain.User, error)
+	// GetCurrentByID reads the whole user past any cache, for reads whose
+	// version must be current or that are written back with Update
+	GetCurrentByID(ctx context.Context, id string) (*domain.User, error)
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	// Update writes the user if it is still at user.Version
 	Update(ctx context.Context, user *domain.User) error
-	Delete(ctx context.Context, id string) error
+	// Delete marks the user as deleted if it is still at version
+	Delete(ctx context.Context, id string, version int64) error
 	UpdateLastLogin(ctx context.Context, id string) error
 	SetPasswordResetToken(ctx context.Context, email, token string, expiry int64) error
 	GetByPasswordResetToken(ctx context.Context, token string) (*domain.User, error)
 	ClearPasswordResetToken(ctx context.Context, id string) error
+	GetByExternalIdentity(ctx context.Context, provider, subject string) (*domain.User, error)
+	LinkExternalIdentity(ctx context.Context, id string, identity domain.ExternalIdentity) error
+	Restore(ctx context.Context, id string) error
+	// ListDeletedBefore returns the IDs of the users PurgeDeletedBefore
+	// would purge, so that data kept elsewhere can be removed first
+	ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error)
+	// PurgeDeletedBefore anonymises the users deleted before cutoff and
+	// returns how many it purged
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
 }
 
 // SessionRepository defines the interface for session management
@@ -25,4 +44,54 @@ type SessionRepository interface {
 	Delete(ctx context.Context, sessionID string) error
 	DeleteAllUserSessions(ctx context.Context, userID string) error
 	Update(ctx context.Context, session *domain.Session) error
+	Count(ctx context.Context) (int64, error)
+}
+
+// OIDCStateRepository defines the interface for pending OpenID Connect logins
+type OIDCStateRepository interface {
+	Save(ctx context.Context, state *domain.OIDCAuthState, ttl time.Duration) error
+	Consume(ctx context.Context, state string) (*domain.OIDCAuthState, error)
+}
+
+// APIKeyRepository defines the interface for API key data access
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByID(ctx context.Context, id string) (*domain.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	List(ctx context.Context) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	UpdateLastUsed(ctx context.Context, id string) error
+}
+
+// LoginEventRepository defines the interface for login history data access
+type LoginEventRepository interface {
+	Create(ctx context.Context, event *domain.LoginEvent) error
+	ListRecentByUser(ctx context.Context, userID string, limit int) ([]*domain.LoginEvent, error)
+	// DeleteByUsers deletes the login history of the given users and
+	// returns how many events it deleted
+	DeleteByUsers(ctx context.Context, userIDs []string) (int64, error)
+}
+
+// LoginChallengeRepository defines the interface for pending step-up verifications
+type LoginChallengeRepository interface {
+	Save(ctx context.Context, challenge *domain.LoginChallenge) error
+	Get(ctx context.Context, id string) (*domain.LoginChallenge, error)
+	// RecordAttempt atomically counts an attempt at the challenge and returns
+	// the number of attempts so far
+	RecordAttempt(ctx context.Context, id string) (int, error)
+	// Consume atomically deletes the challenge, failing with
+	// domain.ErrInvalidLoginCode when it was already consumed or expired
+	Consume(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+}
+
+// NetworkPolicyRepository defines the interface for network policy data access
+type NetworkPolicyRepository interface {
+	List(ctx context.Context) ([]*domain.NetworkPolicy, error)
+	Upsert(ctx context.Context, policy *domain.NetworkPolicy) error
+}
+
+// AuditRepository defines the interface for audit log data access
+type AuditRepository interface {
+	Create(ctx context.Context, entry *domain.AuditEntry) error
 }
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"sync"
+)
+
+type memoryLoginChallengeRepository struct {
+	clock clock.Clock
+
+	mu         sync.Mutex
+	challenges map[string]domain.LoginChallenge
+}
+
+// NewMemoryLoginChallengeRepository creates a login challenge repository that
+// keeps challenges in memory until the clock passes their expiry. It is meant
+// for tests.
+func NewMemoryLoginChallengeRepository(clock clock.Clock) LoginChallengeRepository {
+	return &memoryLoginChallengeRepository{
+		clock:      clock,
+		challenges: make(map[string]domain.LoginChallenge),
+	}
+}
+
+func (r *memoryLoginChallengeRepository) Save(ctx context.Context, challenge *domain.LoginChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.challenges[challenge.ID] = *challenge
+	return nil
+}
+
+func (r *memoryLoginChallengeRepository) Get(ctx context.Context, id string) (*domain.LoginChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, err := r.findLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *memoryLoginChallengeRepository) RecordAttempt(ctx context.Context, id string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, err := r.findLocked(id)
+	if err != nil {
+		return 0, err
+	}
+	challenge.Attempts++
+	r.challenges[id] = challenge
+	return challenge.Attempts, nil
+}
+
+func (r *memoryLoginChallengeRepository) Consume(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.findLocked(id); err != nil {
+		return err
+	}
+	delete(r.challenges, id)
+	return nil
+}
+
+func (r *memoryLoginChallengeRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.challenges, id)
+	return nil
+}
+
+// findLocked returns the challenge unless it is missing or expired. r.mu must
+// be held.
+func (r *memoryLoginChallengeRepository) findLocked(id string) (domain.LoginChallenge, error) {
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return domain.LoginChallenge{}, domain.ErrInvalidLoginCode
+	}
+	if !r.clock.Now().Before(challenge.ExpiresAt) {
+		delete(r.challenges, id)
+		return domain.LoginChallenge{}, domain.ErrInvalidLoginCode
+	}
+	return challenge, nil
+}
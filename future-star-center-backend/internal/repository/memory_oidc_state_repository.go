@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"sync"
+	"time"
+)
+
+type memoryOIDCStateRepository struct {
+	clock clock.Clock
+
+	mu     sync.Mutex
+	states map[string]memoryOIDCState
+}
+
+type memoryOIDCState struct {
+	state     domain.OIDCAuthState
+	expiresAt time.Time
+}
+
+// NewMemoryOIDCStateRepository creates an OIDC state repository that keeps
+// pending logins in memory until the clock passes their expiry. It is meant
+// for tests.
+func NewMemoryOIDCStateRepository(clock clock.Clock) OIDCStateRepository {
+	return &memoryOIDCStateRepository{
+		clock:  clock,
+		states: make(map[string]memoryOIDCState),
+	}
+}
+
+func (r *memoryOIDCStateRepository) Save(ctx context.Context, state *domain.OIDCAuthState, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.states[state.State] = memoryOIDCState{state: *state, expiresAt: r.clock.Now().Add(ttl)}
+	return nil
+}
+
+func (r *memoryOIDCStateRepository) Consume(ctx context.Context, state string) (*domain.OIDCAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.states[state]
+	delete(r.states, state)
+	if !ok || !r.clock.Now().Before(stored.expiresAt) {
+		return nil, domain.ErrInvalidLoginState
+	}
+	return &stored.state, nil
+}
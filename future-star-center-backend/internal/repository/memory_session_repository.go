@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"sync"
+)
+
+type memorySessionRepository struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	sessions map[string]domain.Session
+	// userSessions indexes session IDs by user ID
+	userSessions map[string]map[string]struct{}
+}
+
+// NewMemorySessionRepository creates a session repository that keeps
+// sessions in memory. Like Redis keys with a TTL, sessions disappear once
+// the clock passes their expiry. It is meant for tests.
+func NewMemorySessionRepository(clock clock.Clock) SessionRepository {
+	return &memorySessionRepository{
+		clock:        clock,
+		sessions:     make(map[string]domain.Session),
+		userSessions: make(map[string]map[string]struct{}),
+	}
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = *session
+	if r.userSessions[session.UserID] == nil {
+		r.userSessions[session.UserID] = make(map[string]struct{})
+	}
+	r.userSessions[session.UserID][session.ID] = struct{}{}
+	return nil
+}
+
+func (r *memorySessionRepository) Get(ctx context.Context, sessionID string) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.liveLocked(sessionID)
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) Delete(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteLocked(sessionID)
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteAllUserSessions(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for sessionID := range r.userSessions[userID] {
+		delete(r.sessions, sessionID)
+	}
+	delete(r.userSessions, userID)
+	return nil
+}
+
+func (r *memorySessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) Count(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for sessionID := range r.sessions {
+		if _, ok := r.liveLocked(sessionID); ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// liveLocked returns a session that has not expired, evicting it once it
+// has. The caller must hold the lock.
+func (r *memorySessionRepository) liveLocked(sessionID string) (domain.Session, bool) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return domain.Session{}, false
+	}
+	if !session.IsValid(r.clock.Now()) {
+		r.deleteLocked(sessionID)
+		return domain.Session{}, false
+	}
+	return session, true
+}
+
+// deleteLocked removes a session and its index entry. The caller must hold
+// the lock.
+func (r *memorySessionRepository) deleteLocked(sessionID string) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return
+	}
+
+	delete(r.sessions, sessionID)
+	delete(r.userSessions[session.UserID], sessionID)
+	if len(r.userSessions[session.UserID]) == 0 {
+		delete(r.userSessions, session.UserID)
+	}
+}
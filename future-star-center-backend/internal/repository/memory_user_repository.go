@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUserRepository struct {
+	clock clock.Clock
+
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]*domain.User
+}
+
+// NewMemoryUserRepository creates a user repository that keeps users in
+// memory, behaving like the MongoDB repository. It is meant for tests.
+func NewMemoryUserRepository(clock clock.Clock) UserRepository {
+	return &memoryUserRepository{
+		clock: clock,
+		users: make(map[primitive.ObjectID]*domain.User),
+	}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Emails are unique, as enforced by the MongoDB index
+	if r.findLocked(func(u *domain.User) bool { return u.Email == user.Email }) != nil {
+		return domain.ErrUserExists
+	}
+
+	now := r.clock.Now()
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.IsActive = true
+	user.EmailVerified = false
+	user.Version = 1
+
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	user, err := r.GetCurrentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return withoutCredentials(user), nil
+}
+
+func (r *memoryUserRepository) GetCurrentByID(ctx context.Context, id string) (*domain.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrInvalidID
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[objectID]
+	if !ok || user.DeletedAt != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.findLocked(func(u *domain.User) bool { return u.Email == email })
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok || stored.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	if stored.Version != user.Version {
+		return domain.ErrVersionConflict
+	}
+	if r.findLocked(func(u *domain.User) bool { return u.Email == user.Email && u.ID != user.ID }) != nil {
+		return domain.ErrUserExists
+	}
+
+	// Like the MongoDB repository, only the fields the version guards are
+	// written
+	user.UpdatedAt = r.clock.Now()
+	user.Version++
+	stored.Email = user.Email
+	stored.Password = user.Password
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Role = user.Role
+	stored.IsActive = user.IsActive
+	stored.UpdatedAt = user.UpdatedAt
+	stored.Version = user.Version
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id string, version int64) error {
+	return r.modify(id, func(user *domain.User, now time.Time) error {
+		if user.Version != version {
+			return domain.ErrVersionConflict
+		}
+		user.DeletedAt = &now
+		user.Version++
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) UpdateLastLogin(ctx context.Context, id string) error {
+	return r.modify(id, func(user *domain.User, now time.Time) error {
+		user.LastLogin = &now
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) SetPasswordResetToken(ctx context.Context, email, token string, expiry int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.findLocked(func(u *domain.User) bool { return u.Email == email })
+	if user == nil {
+		return domain.ErrUserNotFound
+	}
+
+	expiryTime := time.Unix(expiry, 0)
+	user.PasswordResetToken = &token
+	user.PasswordResetExpiry = &expiryTime
+	user.UpdatedAt = r.clock.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) GetByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.clock.Now()
+	user := r.findLocked(func(u *domain.User) bool {
+		return u.PasswordResetToken != nil && *u.PasswordResetToken == token &&
+			u.PasswordResetExpiry != nil && u.PasswordResetExpiry.After(now)
+	})
+	if user == nil {
+		return nil, domain.ErrInvalidResetToken
+	}
+	return copyUser(user), nil
+}
+
+func (r *memoryUserRepository) ClearPasswordResetToken(ctx context.Context, id string) error {
+	return r.modify(id, func(user *domain.User, now time.Time) error {
+		user.PasswordResetToken = nil
+		user.PasswordResetExpiry = nil
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) GetByExternalIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.findLocked(func(u *domain.User) bool {
+		for _, identity := range u.ExternalIdentities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return true
+			}
+		}
+		return false
+	})
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (r *memoryUserRepository) LinkExternalIdentity(ctx context.Context, id string, identity domain.ExternalIdentity) error {
+	err := r.modify(id, func(user *domain.User, now time.Time) error {
+		for _, linked := range user.ExternalIdentities {
+			if linked.Provider == identity.Provider {
+				return domain.ErrIdentityLinked
+			}
+		}
+
+		// The provider's verified email proves ownership of the address
+		user.ExternalIdentities = append(user.ExternalIdentities, identity)
+		user.EmailVerified = true
+		return nil
+	})
+
+	// Like the MongoDB repository, a missing user is reported as already linked
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return domain.ErrIdentityLinked
+	}
+	return err
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[objectID]
+	if !ok || user.DeletedAt == nil || user.PurgedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	// Another user may have taken the email meanwhile
+	if r.findLocked(func(u *domain.User) bool { return u.Email == user.Email }) != nil {
+		return domain.ErrUserExists
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = r.clock.Now()
+	user.Version++
+	return nil
+}
+
+func (r *memoryUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []string{}
+	for _, user := range r.users {
+		if purgeable(user, cutoff) {
+			ids = append(ids, user.ID.Hex())
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryUserRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	var purged int64
+	for _, user := range r.users {
+		if !purgeable(user, cutoff) {
+			continue
+		}
+
+		user.Email = ""
+		user.Password = ""
+		user.FirstName = ""
+		user.LastName = ""
+		user.LastLogin = nil
+		user.PasswordResetToken = nil
+		user.PasswordResetExpiry = nil
+		user.ExternalIdentities = nil
+		user.PurgedAt = &now
+		user.UpdatedAt = now
+		user.Version++
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeable reports whether user was deleted before cutoff and has not been
+// purged yet
+func purgeable(user *domain.User, cutoff time.Time) bool {
+	return user.DeletedAt != nil && user.DeletedAt.Before(cutoff) && user.PurgedAt == nil
+}
+
+// modify applies fn to the live user with the given ID and bumps UpdatedAt.
+// Only writes to the fields Update writes, or deleting, move the version.
+func (r *memoryUserRepository) modify(id string, fn func(user *domain.User, now time.Time) error) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[objectID]
+	if !ok || user.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+
+	now := r.clock.Now()
+	if err := fn(user, now); err != nil {
+		return err
+	}
+	user.UpdatedAt = now
+	return nil
+}
+
+// findLocked returns the first live user matching the predicate. The caller
+// must hold the lock.
+func (r *memoryUserRepository) findLocked(match func(*domain.User) bool) *domain.User {
+	for _, user := range r.users {
+		if user.DeletedAt == nil && match(user) {
+			return user
+		}
+	}
+	return nil
+}
+
+// copyUser copies a user so callers never share memory with the store
+func copyUser(user *domain.User) *domain.User {
+	copied := *user
+	if user.LastLogin != nil {
+		lastLogin := *user.LastLogin
+		copied.LastLogin = &lastLogin
+	}
+	if user.PasswordResetToken != nil {
+		token := *user.PasswordResetToken
+		copied.PasswordResetToken = &token
+	}
+	if user.PasswordResetExpiry != nil {
+		expiry := *user.PasswordResetExpiry
+		copied.PasswordResetExpiry = &expiry
+	}
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		copied.DeletedAt = &deletedAt
+	}
+	if user.PurgedAt != nil {
+		purgedAt := *user.PurgedAt
+		copied.PurgedAt = &purgedAt
+	}
+	copied.ExternalIdentities = append([]domain.ExternalIdentity(nil), user.ExternalIdentities...)
+	return &copied
+}
+
+// withoutCredentials clears what GetByID leaves out: the password hash and
+// any pending reset token
+func withoutCredentials(user *domain.User) *domain.User {
+	user.Password = ""
+	user.PasswordResetToken = nil
+	user.PasswordResetExpiry = nil
+	return user
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoAuditRepository struct {
+	collection *mongo.Collection
+	clock      clock.Clock
+}
+
+// NewMongoAuditRepository creates a new MongoDB audit log repository
+func NewMongoAuditRepository(db *mongo.Database, clock clock.Clock) AuditRepository {
+	return &mongoAuditRepository{
+		collection: db.Collection("audit_logs"),
+		clock:      clock,
+	}
+}
+
+func (r *mongoAuditRepository) Create(ctx context.Context, entry *domain.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = r.clock.Now()
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLoginEventRepository struct {
+	collection *mongo.Collection
+	clock      clock.Clock
+}
+
+// NewMongoLoginEventRepository creates a new MongoDB login event repository
+func NewMongoLoginEventRepository(db *mongo.Database, clock clock.Clock) LoginEventRepository {
+	return &mongoLoginEventRepository{
+		collection: db.Collection("login_events"),
+		clock:      clock,
+	}
+}
+
+func (r *mongoLoginEventRepository) Create(ctx context.Context, event *domain.LoginEvent) error {
+	event.ID = primitive.NewObjectID()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = r.clock.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+func (r *mongoLoginEventRepository) ListRecentByUser(ctx context.Context, userID string, limit int) ([]*domain.LoginEvent, error) {
+	opts := options.Find().
+		SetSort(bson.M{"created_at": -1}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*domain.LoginEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *mongoLoginEventRepository) DeleteByUsers(ctx context.Context, userIDs []string) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"user_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
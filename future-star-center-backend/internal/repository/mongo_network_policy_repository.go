@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoNetworkPolicyRepository struct {
+	collection *mongo.Collection
+	clock      clock.Clock
+}
+
+// NewMongoNetworkPolicyRepository creates a new MongoDB network policy repository
+func NewMongoNetworkPolicyRepository(db *mongo.Database, clock clock.Clock) NetworkPolicyRepository {
+	return &mongoNetworkPolicyRepository{
+		collection: db.Collection("network_policies"),
+		clock:      clock,
+	}
+}
+
+func (r *mongoNetworkPolicyRepository) List(ctx context.Context) ([]*domain.NetworkPolicy, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	policies := []*domain.NetworkPolicy{}
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *mongoNetworkPolicyRepository) Upsert(ctx context.Context, policy *domain.NetworkPolicy) error {
+	policy.UpdatedAt = r.clock.Now()
+
+	filter := bson.M{"_id": policy.Role}
+	_, err := r.collection.ReplaceOne(ctx, filter, policy, options.Replace().SetUpsert(true))
+	return err
+}
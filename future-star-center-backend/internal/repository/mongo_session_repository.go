@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSessionRepository struct {
+	collection *mongo.Collection
+	clock      clock.Clock
+}
+
+// NewMongoSessionRepository creates a MongoDB session repository, for
+// deployments that would rather not run Redis for sessions. A TTL index on
+// expires_at removes expired sessions; until MongoDB's TTL monitor gets to
+// them, queries skip them.
+func NewMongoSessionRepository(db *mongo.Database, clock clock.Clock) SessionRepository {
+	return &mongoSessionRepository{
+		collection: db.Collection("sessions"),
+		clock:      clock,
+	}
+}
+
+func (r *mongoSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *mongoSessionRepository) Get(ctx context.Context, sessionID string) (*domain.Session, error) {
+	var session domain.Session
+	err := r.collection.FindOne(ctx, r.live(bson.M{"_id": sessionID})).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *mongoSessionRepository) Delete(ctx context.Context, sessionID string) error {
+	// Deleting a missing session is not an error
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": sessionID})
+	return err
+}
+
+func (r *mongoSessionRepository) DeleteAllUserSessions(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *mongoSessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	// Like a Redis SET, updating stores the session whether or not it exists
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": session.ID}, session, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoSessionRepository) Count(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, r.live(bson.M{}))
+}
+
+// live restricts filter to sessions that have not expired
+func (r *mongoSessionRepository) live(filter bson.M) bson.M {
+	filter["expires_at"] = bson.M{"$gt": r.clock.Now()}
+	return filter
+}
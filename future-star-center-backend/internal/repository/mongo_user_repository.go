@@ -3,36 +3,49 @@ package repository
 import (
 	"context"
 	"errors"
+	"future-star-center-backend/internal/clock"
 	"future-star-center-backend/internal/domain"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// withoutCredentialsProjection leaves out the fields GetByID omits
+var withoutCredentialsProjection = bson.M{
+	"password":              0,
+	"password_reset_token":  0,
+	"password_reset_expiry": 0,
+}
+
 type mongoUserRepository struct {
 	collection *mongo.Collection
+	clock      clock.Clock
 }
 
 // NewMongoUserRepository creates a new MongoDB user repository
-func NewMongoUserRepository(db *mongo.Database) UserRepository {
+func NewMongoUserRepository(db *mongo.Database, clock clock.Clock) UserRepository {
 	return &mongoUserRepository{
 		collection: db.Collection("users"),
+		clock:      clock,
 	}
 }
 
 func (r *mongoUserRepository) Create(ctx context.Context, user *domain.User) error {
+	now := r.clock.Now()
 	user.ID = primitive.NewObjectID()
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
 	user.IsActive = true
 	user.EmailVerified = false
+	user.Version = 1
 
 	_, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
-			return errors.New("user with this email already exists")
+			return domain.ErrUserExists
 		}
 		return err
 	}
@@ -40,16 +53,25 @@ func (r *mongoUserRepository) Create(ctx context.Context, user *domain.User) err
 }
 
 func (r *mongoUserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.findByID(ctx, id, options.FindOne().SetProjection(withoutCredentialsProjection))
+}
+
+// GetCurrentByID reads the whole user from the database, which has no cache
+func (r *mongoUserRepository) GetCurrentByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.findByID(ctx, id)
+}
+
+func (r *mongoUserRepository) findByID(ctx context.Context, id string, opts ...*options.FindOneOptions) (*domain.User, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid user ID")
+		return nil, domain.ErrInvalidID
 	}
 
 	var user domain.User
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	err = r.collection.FindOne(ctx, live(bson.M{"_id": objectID}), opts...).Decode(&user)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -58,47 +80,79 @@ func (r *mongoUserRepository) GetByID(ctx context.Context, id string) (*domain.U
 
 func (r *mongoUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	err := r.collection.FindOne(ctx, live(bson.M{"email": email})).Decode(&user)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
 		}
 		return nil, err
 	}
 	return &user, nil
 }
 
+// Update writes the user's profile, credentials, role and active flag if it
+// is still at user.Version, moving it to the next version. Only those fields
+// are written, which the version guards; fields with writes of their own,
+// such as the last login, are left alone. A user changed since it was read is
+// reported as domain.ErrVersionConflict.
 func (r *mongoUserRepository) Update(ctx context.Context, user *domain.User) error {
-	user.UpdatedAt = time.Now()
-
-	filter := bson.M{"_id": user.ID}
-	update := bson.M{"$set": user}
+	now := r.clock.Now()
+	filter := live(bson.M{"_id": user.ID, "version": user.Version})
+	update := bson.M{
+		"$set": bson.M{
+			"email":      user.Email,
+			"password":   user.Password,
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"role":       user.Role,
+			"is_active":  user.IsActive,
+			"updated_at": now,
+		},
+		"$inc": bson.M{"version": 1},
+	}
 
 	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrUserExists
+		}
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("user not found")
+		return r.conflictOrNotFound(ctx, user.ID)
 	}
 
+	user.Version++
+	user.UpdatedAt = now
 	return nil
 }
 
-func (r *mongoUserRepository) Delete(ctx context.Context, id string) error {
+// Delete marks the user as deleted if it is still at version, keeping the
+// document so that records referring to it stay resolvable
+func (r *mongoUserRepository) Delete(ctx context.Context, id string, version int64) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return errors.New("invalid user ID")
+		return domain.ErrInvalidID
 	}
 
-	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	now := r.clock.Now()
+	filter := live(bson.M{"_id": objectID, "version": version})
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at": now,
+			"updated_at": now,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
 
-	if result.DeletedCount == 0 {
-		return errors.New("user not found")
+	if result.MatchedCount == 0 {
+		return r.conflictOrNotFound(ctx, objectID)
 	}
 
 	return nil
@@ -107,11 +161,11 @@ func (r *mongoUserRepository) Delete(ctx context.Context, id string) error {
 func (r *mongoUserRepository) UpdateLastLogin(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return errors.New("invalid user ID")
+		return domain.ErrInvalidID
 	}
 
-	now := time.Now()
-	filter := bson.M{"_id": objectID}
+	now := r.clock.Now()
+	filter := live(bson.M{"_id": objectID})
 	update := bson.M{
 		"$set": bson.M{
 			"last_login": &now,
@@ -125,7 +179,7 @@ func (r *mongoUserRepository) UpdateLastLogin(ctx context.Context, id string) er
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("user not found")
+		return domain.ErrUserNotFound
 	}
 
 	return nil
@@ -134,12 +188,12 @@ func (r *mongoUserRepository) UpdateLastLogin(ctx context.Context, id string) er
 func (r *mongoUserRepository) SetPasswordResetToken(ctx context.Context, email, token string, expiry int64) error {
 	expiryTime := time.Unix(expiry, 0)
 
-	filter := bson.M{"email": email}
+	filter := live(bson.M{"email": email})
 	update := bson.M{
 		"$set": bson.M{
 			"password_reset_token":  &token,
 			"password_reset_expiry": &expiryTime,
-			"updated_at":            time.Now(),
+			"updated_at":            r.clock.Now(),
 		},
 	}
 
@@ -149,7 +203,7 @@ func (r *mongoUserRepository) SetPasswordResetToken(ctx context.Context, email,
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("user not found")
+		return domain.ErrUserNotFound
 	}
 
 	return nil
@@ -157,17 +211,17 @@ func (r *mongoUserRepository) SetPasswordResetToken(ctx context.Context, email,
 
 func (r *mongoUserRepository) GetByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
 	var user domain.User
-	filter := bson.M{
+	filter := live(bson.M{
 		"password_reset_token": token,
 		"password_reset_expiry": bson.M{
-			"$gt": time.Now(),
+			"$gt": r.clock.Now(),
 		},
-	}
+	})
 
 	err := r.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("invalid or expired reset token")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrInvalidResetToken
 		}
 		return nil, err
 	}
@@ -177,28 +231,198 @@ func (r *mongoUserRepository) GetByPasswordResetToken(ctx context.Context, token
 func (r *mongoUserRepository) ClearPasswordResetToken(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return errors.New("invalid user ID")
+		return domain.ErrInvalidID
 	}
 
-	filter := bson.M{"_id": objectID}
+	filter := live(bson.M{"_id": objectID})
 	update := bson.M{
 		"$unset": bson.M{
 			"password_reset_token":  "",
 			"password_reset_expiry": "",
 		},
 		"$set": bson.M{
-			"updated_at": time.Now(),
+			"updated_at": r.clock.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoUserRepository) GetByExternalIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	var user domain.User
+	filter := live(bson.M{
+		"external_identities": bson.M{
+			"$elemMatch": bson.M{
+				"provider": provider,
+				"subject":  subject,
+			},
+		},
+	})
+
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) LinkExternalIdentity(ctx context.Context, id string, identity domain.ExternalIdentity) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidID
+	}
+
+	// The provider's verified email proves ownership of the address
+	filter := live(bson.M{
+		"_id":                          objectID,
+		"external_identities.provider": bson.M{"$ne": identity.Provider},
+	})
+	update := bson.M{
+		"$push": bson.M{
+			"external_identities": identity,
+		},
+		"$set": bson.M{
+			"email_verified": true,
+			"updated_at":     r.clock.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return domain.ErrIdentityLinked
+	}
+
+	return nil
+}
+
+func (r *mongoUserRepository) Restore(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidID
+	}
+
+	filter := bson.M{
+		"_id":        objectID,
+		"deleted_at": bson.M{"$ne": nil},
+		"purged_at":  bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"deleted_at": nil,
+			"updated_at": r.clock.Now(),
 		},
+		"$inc": bson.M{"version": 1},
 	}
 
 	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
+		// Another user took the email or identity meanwhile
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrUserExists
+		}
 		return err
 	}
 
 	if result.MatchedCount == 0 {
-		return errors.New("user not found")
+		return domain.ErrUserNotFound
 	}
 
 	return nil
 }
+
+func (r *mongoUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(ctx, purgeableFilter(cutoff), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []string{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids = append(ids, doc.ID.Hex())
+	}
+	return ids, cursor.Err()
+}
+
+// PurgeDeletedBefore blanks the personal data of users deleted before cutoff.
+// The documents stay, so records referring to a purged user still resolve.
+func (r *mongoUserRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	now := r.clock.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"email":      "",
+			"password":   "",
+			"first_name": "",
+			"last_name":  "",
+			"purged_at":  now,
+			"updated_at": now,
+		},
+		"$unset": bson.M{
+			"last_login":            "",
+			"password_reset_token":  "",
+			"password_reset_expiry": "",
+			"external_identities":   "",
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, purgeableFilter(cutoff), update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// purgeableFilter matches the users deleted before cutoff that have not been
+// purged yet
+func purgeableFilter(cutoff time.Time) bson.M {
+	return bson.M{
+		"deleted_at": bson.M{"$lt": cutoff},
+		"purged_at":  bson.M{"$exists": false},
+	}
+}
+
+// conflictOrNotFound tells why a conditional update of a user matched no
+// document
+func (r *mongoUserRepository) conflictOrNotFound(ctx context.Context, id primitive.ObjectID) error {
+	count, err := r.collection.CountDocuments(ctx, live(bson.M{"_id": id}), options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrVersionConflict
+}
+
+// live restricts filter to users that have not been deleted. Live users
+// store an explicit null deleted_at (see migration 8), and matching it by
+// type rather than with nil, which also matches a missing field, lets the
+// planner use the partial unique indexes.
+func live(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$type": "null"}
+	return filter
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// recordAttemptScript counts an attempt at a login challenge, unless it has
+// expired or been consumed, and returns the number of attempts so far or -1.
+//
+// KEYS[1] is the challenge.
+var recordAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+`)
+
+type redisLoginChallengeRepository struct {
+	client redis.UniversalClient
+	clock  clock.Clock
+}
+
+// NewRedisLoginChallengeRepository creates a new Redis login challenge
+// repository. Challenges are hashes under login_challenge:<id> holding the
+// challenge and its attempt counter, so that attempts can be counted and the
+// challenge consumed atomically.
+func NewRedisLoginChallengeRepository(client redis.UniversalClient, clock clock.Clock) LoginChallengeRepository {
+	return &redisLoginChallengeRepository{
+		client: client,
+		clock:  clock,
+	}
+}
+
+func (r *redisLoginChallengeRepository) Save(ctx context.Context, challenge *domain.LoginChallenge) error {
+	challengeData, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	challengeKey := loginChallengeKey(challenge.ID)
+	duration := challenge.ExpiresAt.Sub(r.clock.Now())
+	if duration <= 0 {
+		return domain.ErrInvalidLoginCode
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, challengeKey, "data", challengeData, "attempts", challenge.Attempts)
+		pipe.Expire(ctx, challengeKey, duration)
+		return nil
+	})
+	return err
+}
+
+func (r *redisLoginChallengeRepository) Get(ctx context.Context, id string) (*domain.LoginChallenge, error) {
+	fields, err := r.client.HGetAll(ctx, loginChallengeKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if fields["data"] == "" {
+		return nil, domain.ErrInvalidLoginCode
+	}
+
+	var challenge domain.LoginChallenge
+	err = json.Unmarshal([]byte(fields["data"]), &challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge.Attempts, err = strconv.Atoi(fields["attempts"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid login challenge attempts: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+func (r *redisLoginChallengeRepository) RecordAttempt(ctx context.Context, id string) (int, error) {
+	attempts, err := recordAttemptScript.Run(ctx, r.client, []string{loginChallengeKey(id)}).Int()
+	if err != nil {
+		return 0, err
+	}
+	if attempts < 0 {
+		return 0, domain.ErrInvalidLoginCode
+	}
+	return attempts, nil
+}
+
+func (r *redisLoginChallengeRepository) Consume(ctx context.Context, id string) error {
+	deleted, err := r.client.Del(ctx, loginChallengeKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return domain.ErrInvalidLoginCode
+	}
+	return nil
+}
+
+func (r *redisLoginChallengeRepository) Delete(ctx context.Context, id string) error {
+	return r.client.Del(ctx, loginChallengeKey(id)).Err()
+}
+
+func loginChallengeKey(id string) string {
+	return fmt.Sprintf("login_challenge:%s", id)
+}
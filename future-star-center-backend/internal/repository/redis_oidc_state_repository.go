@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisOIDCStateRepository struct {
+	client redis.UniversalClient
+}
+
+// NewRedisOIDCStateRepository creates a new Redis OIDC state repository
+func NewRedisOIDCStateRepository(client redis.UniversalClient) OIDCStateRepository {
+	return &redisOIDCStateRepository{
+		client: client,
+	}
+}
+
+func (r *redisOIDCStateRepository) Save(ctx context.Context, state *domain.OIDCAuthState, ttl time.Duration) error {
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	stateKey := fmt.Sprintf("oidc_state:%s", state.State)
+	return r.client.Set(ctx, stateKey, stateData, ttl).Err()
+}
+
+func (r *redisOIDCStateRepository) Consume(ctx context.Context, state string) (*domain.OIDCAuthState, error) {
+	stateKey := fmt.Sprintf("oidc_state:%s", state)
+
+	// GETDEL makes each state single-use, even under concurrent callbacks
+	stateData, err := r.client.GetDel(ctx, stateKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, domain.ErrInvalidLoginState
+		}
+		return nil, err
+	}
+
+	var authState domain.OIDCAuthState
+	err = json.Unmarshal([]byte(stateData), &authState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authState, nil
+}
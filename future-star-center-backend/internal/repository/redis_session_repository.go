@@ -5,61 +5,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"future-star-center-backend/internal/clock"
 	"future-star-center-backend/internal/domain"
-	"time"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// indexSessionScript adds a session to a user's session index, a sorted set
+// of session IDs scored by expiry in Unix milliseconds. It prunes sessions
+// that have expired and keeps the index alive as long as its longest-lived
+// session, never shortening it.
+//
+// KEYS[1] is the index, ARGV[1] the session's expiry, ARGV[2] its ID and
+// ARGV[3] the current time.
+var indexSessionScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+redis.call('PEXPIREAT', KEYS[1], last[2])
+return 1
+`)
+
 type redisSessionRepository struct {
-	client *redis.Client
+	client redis.UniversalClient
+	logger *slog.Logger
+	clock  clock.Clock
 }
 
-// NewRedisSessionRepository creates a new Redis session repository
-func NewRedisSessionRepository(client *redis.Client) SessionRepository {
+// NewRedisSessionRepository creates a new Redis session repository.
+//
+// Sessions are stored under session:<id> with a TTL matching their expiry.
+// Each user's sessions are indexed in user_session_index:<user ID>, a sorted
+// set scored by expiry that is pruned lazily. Writes touching both keys run
+// in a MULTI/EXEC transaction; scripts only touch a single key, so that the
+// keys may live on different Redis Cluster slots. In a cluster, each slot's
+// writes are atomic and the index's lazy pruning absorbs the gap between them.
+func NewRedisSessionRepository(client redis.UniversalClient, logger *slog.Logger, clock clock.Clock) SessionRepository {
 	return &redisSessionRepository{
 		client: client,
+		logger: logger,
+		clock:  clock,
 	}
 }
 
 func (r *redisSessionRepository) Create(ctx context.Context, session *domain.Session) error {
-	sessionData, err := json.Marshal(session)
-	if err != nil {
-		return err
-	}
-
-	sessionKey := fmt.Sprintf("session:%s", session.ID)
-	userSessionKey := fmt.Sprintf("user_sessions:%s", session.UserID)
-
-	// Set session data with expiration
-	duration := time.Until(session.ExpiresAt)
-	err = r.client.Set(ctx, sessionKey, sessionData, duration).Err()
-	if err != nil {
-		return err
-	}
-
-	// Add session ID to user's session set
-	err = r.client.SAdd(ctx, userSessionKey, session.ID).Err()
-	if err != nil {
-		return err
-	}
-
-	// Set expiration for user sessions set
-	err = r.client.Expire(ctx, userSessionKey, duration).Err()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return r.save(ctx, session)
 }
 
 func (r *redisSessionRepository) Get(ctx context.Context, sessionID string) (*domain.Session, error) {
-	sessionKey := fmt.Sprintf("session:%s", sessionID)
-
-	sessionData, err := r.client.Get(ctx, sessionKey).Result()
+	sessionData, err := r.client.Get(ctx, sessionKey(sessionID)).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return nil, errors.New("session not found")
+		if errors.Is(err, redis.Nil) {
+			return nil, domain.ErrSessionNotFound
 		}
 		return nil, err
 	}
@@ -71,60 +71,148 @@ func (r *redisSessionRepository) Get(ctx context.Context, sessionID string) (*do
 	}
 
 	// Check if session is still valid
-	if !session.IsValid() {
+	if !session.IsValid(r.clock.Now()) {
 		// Clean up expired session
-		r.Delete(ctx, sessionID)
-		return nil, errors.New("session expired")
+		if err := r.remove(ctx, &session); err != nil {
+			r.logger.WarnContext(ctx, "failed to clean up expired session", "error", err)
+		}
+		return nil, domain.ErrSessionNotFound
 	}
 
 	return &session, nil
 }
 
 func (r *redisSessionRepository) Delete(ctx context.Context, sessionID string) error {
-	sessionKey := fmt.Sprintf("session:%s", sessionID)
-
 	// Get session to find user ID
 	session, err := r.Get(ctx, sessionID)
 	if err != nil {
 		// If session doesn't exist, consider it deleted
-		return nil
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return nil
+		}
+		return err
 	}
 
-	// Remove from user's session set
-	userSessionKey := fmt.Sprintf("user_sessions:%s", session.UserID)
-	r.client.SRem(ctx, userSessionKey, sessionID)
-
-	// Delete the session
-	return r.client.Del(ctx, sessionKey).Err()
+	return r.remove(ctx, session)
 }
 
 func (r *redisSessionRepository) DeleteAllUserSessions(ctx context.Context, userID string) error {
-	userSessionKey := fmt.Sprintf("user_sessions:%s", userID)
+	indexKey := userSessionIndexKey(userID)
 
-	// Get all session IDs for this user
-	sessionIDs, err := r.client.SMembers(ctx, userSessionKey).Result()
+	sessionIDs, err := r.client.ZRange(ctx, indexKey, 0, -1).Result()
 	if err != nil {
 		return err
 	}
 
-	// Delete each session
-	for _, sessionID := range sessionIDs {
-		sessionKey := fmt.Sprintf("session:%s", sessionID)
-		r.client.Del(ctx, sessionKey)
+	// Sessions created before the index became a sorted set are listed in
+	// the old set until it expires
+	legacyKey := legacyUserSessionsKey(userID)
+	legacyIDs, err := r.client.SMembers(ctx, legacyKey).Result()
+	if err != nil {
+		return err
 	}
 
-	// Delete the user sessions set
-	return r.client.Del(ctx, userSessionKey).Err()
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sessionID := range append(sessionIDs, legacyIDs...) {
+			pipe.Del(ctx, sessionKey(sessionID))
+		}
+		// Remove only the sessions read above, keeping any created meanwhile
+		if len(sessionIDs) > 0 {
+			pipe.ZRem(ctx, indexKey, stringsToMembers(sessionIDs)...)
+		}
+		pipe.Del(ctx, legacyKey)
+		return nil
+	})
+	return err
 }
 
 func (r *redisSessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	return r.save(ctx, session)
+}
+
+func (r *redisSessionRepository) Count(ctx context.Context) (int64, error) {
+	// A cluster's sessions are spread over its masters, which are scanned
+	// one by one
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return countSessions(ctx, r.client)
+	}
+
+	var count atomic.Int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		n, err := countSessions(ctx, master)
+		count.Add(n)
+		return err
+	})
+	return count.Load(), err
+}
+
+// save stores a session and indexes it under its user in one transaction.
+// Sessions that have already expired are removed instead.
+func (r *redisSessionRepository) save(ctx context.Context, session *domain.Session) error {
 	sessionData, err := json.Marshal(session)
 	if err != nil {
 		return err
 	}
 
-	sessionKey := fmt.Sprintf("session:%s", session.ID)
-	duration := time.Until(session.ExpiresAt)
+	now := r.clock.Now()
+	duration := session.ExpiresAt.Sub(now)
+	if duration <= 0 {
+		return r.remove(ctx, session)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(session.ID), sessionData, duration)
+		indexSessionScript.Eval(ctx, pipe,
+			[]string{userSessionIndexKey(session.UserID)},
+			strconv.FormatInt(session.ExpiresAt.UnixMilli(), 10),
+			session.ID,
+			strconv.FormatInt(now.UnixMilli(), 10),
+		)
+		return nil
+	})
+	return err
+}
 
-	return r.client.Set(ctx, sessionKey, sessionData, duration).Err()
+// remove deletes a session and its entry in the user's session index in one
+// transaction
+func (r *redisSessionRepository) remove(ctx context.Context, session *domain.Session) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(session.ID))
+		pipe.ZRem(ctx, userSessionIndexKey(session.UserID), session.ID)
+		return nil
+	})
+	return err
+}
+
+// countSessions counts the session keys of a single Redis server
+func countSessions(ctx context.Context, client redis.Cmdable) (int64, error) {
+	var count int64
+	iter := client.Scan(ctx, 0, "session:*", 1000).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+	return count, iter.Err()
+}
+
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+func userSessionIndexKey(userID string) string {
+	return fmt.Sprintf("user_session_index:%s", userID)
+}
+
+// legacyUserSessionsKey is the unsorted set that indexed a user's sessions
+// before user_session_index:<user ID>
+func legacyUserSessionsKey(userID string) string {
+	return fmt.Sprintf("user_sessions:%s", userID)
+}
+
+func stringsToMembers(values []string) []interface{} {
+	members := make([]interface{}, len(values))
+	for i, value := range values {
+		members[i] = value
+	}
+	return members
 }
@@ -0,0 +1,14 @@
+// Package repositorytest holds the behavioural contract every repository
+// implementation must meet. Each storage backend runs the same tests from its
+// own test file, so a new backend proves it can replace an existing one by
+// passing them:
+//
+//	func TestMemoryUserRepository(t *testing.T) {
+//		repositorytest.TestUserRepository(t, func(t *testing.T, clock clock.Clock) repository.UserRepository {
+//			return repository.NewMemoryUserRepository(clock)
+//		})
+//	}
+//
+// Repositories must read the time from the clock they are given, which the
+// tests advance to check expiry.
+package repositorytest
@@ -0,0 +1,222 @@
+package repositorytest
+
+import (
+	"context"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionRepositoryFactory creates an empty session repository that reads
+// the current time from clock
+type SessionRepositoryFactory func(t *testing.T, clock clock.Clock) repository.SessionRepository
+
+// sessionLifetime is how long the sessions the tests create live
+const sessionLifetime = 2 * time.Hour
+
+// TestSessionRepository checks that the repositories built by newRepo behave
+// as a SessionRepository must. Every subtest gets a fresh repository.
+func TestSessionRepository(t *testing.T, newRepo SessionRepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.SessionRepository, clock *clock.Fake)
+	}{
+		{"CreateAndGet", testSessionCreateAndGet},
+		{"GetReportsMissingSessions", testSessionGetMissing},
+		{"SessionsExpire", testSessionExpiry},
+		{"UpdatePersistsChanges", testSessionUpdate},
+		{"DeleteIsIdempotent", testSessionDelete},
+		{"DeleteAllUserSessions", testSessionDeleteAllUserSessions},
+		{"CountTracksLiveSessions", testSessionCount},
+		{"ConcurrentDelete", testSessionConcurrentDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newClock()
+			tt.run(t, newRepo(t, clock), clock)
+		})
+	}
+}
+
+func testSessionCreateAndGet(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	session := newSession(clock, primitive.NewObjectID().Hex())
+	mustCreateSession(t, repo, session)
+
+	stored, err := repo.Get(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertSession(t, stored, session)
+}
+
+func testSessionGetMissing(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	_, err := repo.Get(context.Background(), primitive.NewObjectID().Hex())
+	assertError(t, "Get(unknown)", err, domain.ErrSessionNotFound)
+}
+
+func testSessionExpiry(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	session := newSession(clock, primitive.NewObjectID().Hex())
+	mustCreateSession(t, repo, session)
+
+	clock.Set(session.ExpiresAt.Add(-time.Second))
+	if _, err := repo.Get(ctx, session.ID); err != nil {
+		t.Fatalf("Get(before expiry) error = %v", err)
+	}
+
+	clock.Set(session.ExpiresAt)
+	_, err := repo.Get(ctx, session.ID)
+	assertError(t, "Get(at expiry)", err, domain.ErrSessionNotFound)
+
+	// Deleting an expired session is not an error
+	if err := repo.Delete(ctx, session.ID); err != nil {
+		t.Errorf("Delete(expired) error = %v", err)
+	}
+}
+
+func testSessionUpdate(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	session := newSession(clock, primitive.NewObjectID().Hex())
+	mustCreateSession(t, repo, session)
+
+	session.Role = domain.RoleAdmin
+	session.ExpiresAt = session.ExpiresAt.Add(time.Hour)
+	if err := repo.Update(ctx, session); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// The session now outlives its original expiry
+	clock.Advance(sessionLifetime)
+	stored, err := repo.Get(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertSession(t, stored, session)
+}
+
+func testSessionDelete(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	session := newSession(clock, primitive.NewObjectID().Hex())
+	mustCreateSession(t, repo, session)
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Delete(ctx, session.ID); err != nil {
+			t.Fatalf("Delete() call %d error = %v", i+1, err)
+		}
+	}
+
+	_, err := repo.Get(ctx, session.ID)
+	assertError(t, "Get(deleted)", err, domain.ErrSessionNotFound)
+}
+
+func testSessionDeleteAllUserSessions(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	userID, otherUserID := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+
+	first, second := newSession(clock, userID), newSession(clock, userID)
+	other := newSession(clock, otherUserID)
+	for _, session := range []*domain.Session{first, second, other} {
+		mustCreateSession(t, repo, session)
+	}
+
+	if err := repo.DeleteAllUserSessions(ctx, userID); err != nil {
+		t.Fatalf("DeleteAllUserSessions() error = %v", err)
+	}
+
+	for _, session := range []*domain.Session{first, second} {
+		_, err := repo.Get(ctx, session.ID)
+		assertError(t, "Get(deleted user session)", err, domain.ErrSessionNotFound)
+	}
+	if _, err := repo.Get(ctx, other.ID); err != nil {
+		t.Errorf("Get(other user's session) error = %v", err)
+	}
+
+	// A user without sessions is not an error
+	if err := repo.DeleteAllUserSessions(ctx, userID); err != nil {
+		t.Errorf("DeleteAllUserSessions(no sessions) error = %v", err)
+	}
+}
+
+func testSessionCount(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	userID := primitive.NewObjectID().Hex()
+
+	sessions := []*domain.Session{newSession(clock, userID), newSession(clock, userID), newSession(clock, userID)}
+	for _, session := range sessions {
+		mustCreateSession(t, repo, session)
+	}
+	assertCount(t, repo, 3)
+
+	if err := repo.Delete(ctx, sessions[0].ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	assertCount(t, repo, 2)
+
+	if err := repo.DeleteAllUserSessions(ctx, userID); err != nil {
+		t.Fatalf("DeleteAllUserSessions() error = %v", err)
+	}
+	assertCount(t, repo, 0)
+}
+
+func testSessionConcurrentDelete(t *testing.T, repo repository.SessionRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	session := newSession(clock, primitive.NewObjectID().Hex())
+	mustCreateSession(t, repo, session)
+
+	for _, err := range concurrently(func() error { return repo.Delete(ctx, session.ID) }) {
+		if err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+	}
+
+	_, err := repo.Get(ctx, session.ID)
+	assertError(t, "Get(deleted)", err, domain.ErrSessionNotFound)
+	assertCount(t, repo, 0)
+}
+
+// newSession returns a session for userID that lives for sessionLifetime
+func newSession(clock clock.Clock, userID string) *domain.Session {
+	now := clock.Now()
+	return &domain.Session{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		Email:     "ayu@example.com",
+		Role:      domain.RoleTherapist,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionLifetime),
+	}
+}
+
+func mustCreateSession(t *testing.T, repo repository.SessionRepository, session *domain.Session) {
+	t.Helper()
+
+	if err := repo.Create(context.Background(), session); err != nil {
+		t.Fatalf("Create(%s) error = %v", session.ID, err)
+	}
+}
+
+func assertSession(t *testing.T, got, want *domain.Session) {
+	t.Helper()
+
+	if got.ID != want.ID || got.UserID != want.UserID || got.Email != want.Email || got.Role != want.Role ||
+		!got.CreatedAt.Equal(want.CreatedAt) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("session = %+v, want %+v", got, want)
+	}
+}
+
+func assertCount(t *testing.T, repo repository.SessionRepository, want int64) {
+	t.Helper()
+
+	count, err := repo.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != want {
+		t.Errorf("Count() = %d, want %d", count, want)
+	}
+}
@@ -0,0 +1,672 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepositoryFactory creates an empty user repository that reads the
+// current time from clock
+type UserRepositoryFactory func(t *testing.T, clock clock.Clock) repository.UserRepository
+
+// TestUserRepository checks that the repositories built by newRepo behave as
+// a UserRepository must. Every subtest gets a fresh repository.
+func TestUserRepository(t *testing.T, newRepo UserRepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.UserRepository, clock *clock.Fake)
+	}{
+		{"CreateAssignsIdentityAndDefaults", testUserCreate},
+		{"CreateRejectsDuplicateEmail", testUserCreateDuplicateEmail},
+		{"GetReportsMissingUsers", testUserGetMissing},
+		{"UpdatePersistsChanges", testUserUpdate},
+		{"UpdateRejectsDuplicateEmail", testUserUpdateDuplicateEmail},
+		{"UpdateRejectsStaleVersion", testUserUpdateStaleVersion},
+		{"InternalWritesKeepVersion", testUserInternalWritesKeepVersion},
+		{"UpdateLastLogin", testUserUpdateLastLogin},
+		{"DeleteRemovesUser", testUserDelete},
+		{"DeletedUsersAreHidden", testUserDeletedHidden},
+		{"RestoreUndoesDelete", testUserRestore},
+		{"RestoreRejectsTakenEmail", testUserRestoreTakenEmail},
+		{"PurgeDeletedBefore", testUserPurge},
+		{"PasswordResetTokenExpires", testUserPasswordResetExpiry},
+		{"PasswordResetTokenCanBeCleared", testUserPasswordResetClear},
+		{"LinkExternalIdentity", testUserLinkExternalIdentity},
+		{"ConcurrentCreateWithSameEmail", testUserConcurrentCreate},
+		{"ConcurrentDelete", testUserConcurrentDelete},
+		{"ConcurrentUpdateOfSameVersion", testUserConcurrentUpdate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newClock()
+			tt.run(t, newRepo(t, clock), clock)
+		})
+	}
+}
+
+func testUserCreate(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	user.IsActive = false
+	user.EmailVerified = true
+
+	mustCreateUser(t, repo, user)
+	if user.ID.IsZero() {
+		t.Fatal("Create did not assign an ID")
+	}
+	if !user.CreatedAt.Equal(clock.Now()) || !user.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("timestamps = %v, %v, want %v", user.CreatedAt, user.UpdatedAt, clock.Now())
+	}
+	if !user.IsActive || user.EmailVerified {
+		t.Errorf("IsActive, EmailVerified = %t, %t, want true, false", user.IsActive, user.EmailVerified)
+	}
+	if user.Version != 1 {
+		t.Errorf("Version = %d, want 1", user.Version)
+	}
+
+	current, err := repo.GetCurrentByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetCurrentByID() error = %v", err)
+	}
+	assertUser(t, current, user)
+
+	// GetByID leaves out the password hash, so that it can be cached
+	byID, err := repo.GetByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	withoutPassword := *user
+	withoutPassword.Password = ""
+	assertUser(t, byID, &withoutPassword)
+
+	byEmail, err := repo.GetByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatalf("GetByEmail() error = %v", err)
+	}
+	assertUser(t, byEmail, user)
+}
+
+func testUserCreateDuplicateEmail(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	mustCreateUser(t, repo, newUser("ayu@example.com"))
+
+	err := repo.Create(context.Background(), newUser("ayu@example.com"))
+	assertError(t, "Create()", err, domain.ErrUserExists)
+}
+
+func testUserGetMissing(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+
+	_, err := repo.GetByID(ctx, primitive.NewObjectID().Hex())
+	assertError(t, "GetByID(unknown)", err, domain.ErrUserNotFound)
+
+	_, err = repo.GetByID(ctx, "not-an-id")
+	assertError(t, "GetByID(invalid)", err, domain.ErrInvalidID)
+
+	_, err = repo.GetByEmail(ctx, "nobody@example.com")
+	assertError(t, "GetByEmail()", err, domain.ErrUserNotFound)
+
+	_, err = repo.GetByExternalIdentity(ctx, "google", "subject")
+	assertError(t, "GetByExternalIdentity()", err, domain.ErrUserNotFound)
+}
+
+func testUserUpdate(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	clock.Advance(time.Minute)
+	user.Email = "ayu.lestari@example.com"
+	user.Role = domain.RoleAdmin
+	user.IsActive = false
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !user.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("UpdatedAt = %v, want %v", user.UpdatedAt, clock.Now())
+	}
+	if user.Version != 2 {
+		t.Errorf("Version = %d, want 2", user.Version)
+	}
+
+	stored, err := repo.GetCurrentByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetCurrentByID() error = %v", err)
+	}
+	assertUser(t, stored, user)
+
+	_, err = repo.GetByEmail(ctx, "ayu@example.com")
+	assertError(t, "GetByEmail(old email)", err, domain.ErrUserNotFound)
+
+	missing := newUser("nobody@example.com")
+	missing.ID = primitive.NewObjectID()
+	assertError(t, "Update(unknown)", repo.Update(ctx, missing), domain.ErrUserNotFound)
+}
+
+func testUserUpdateDuplicateEmail(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	mustCreateUser(t, repo, newUser("ayu@example.com"))
+	user := newUser("budi@example.com")
+	mustCreateUser(t, repo, user)
+
+	user.Email = "ayu@example.com"
+	err := repo.Update(context.Background(), user)
+	assertError(t, "Update()", err, domain.ErrUserExists)
+}
+
+func testUserUpdateStaleVersion(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	first, _ := repo.GetCurrentByID(ctx, user.ID.Hex())
+	second, _ := repo.GetCurrentByID(ctx, user.ID.Hex())
+
+	first.FirstName = "Ayunda"
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	second.LastName = "Pratiwi"
+	assertError(t, "Update(stale)", repo.Update(ctx, second), domain.ErrVersionConflict)
+	if second.Version != 1 {
+		t.Errorf("Version after a conflict = %d, want 1", second.Version)
+	}
+
+	stored, err := repo.GetCurrentByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetCurrentByID() error = %v", err)
+	}
+	assertUser(t, stored, first)
+}
+
+func testUserInternalWritesKeepVersion(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+	id := user.ID.Hex()
+
+	// Update only writes the fields the version guards, so writes to other
+	// fields neither move the version nor get overwritten by an update made
+	// against a copy read before them
+	writes := []struct {
+		name    string
+		write   func() error
+		written func(user *domain.User) bool
+	}{
+		{
+			name:    "UpdateLastLogin",
+			write:   func() error { return repo.UpdateLastLogin(ctx, id) },
+			written: func(user *domain.User) bool { return user.LastLogin != nil },
+		},
+		{
+			name: "SetPasswordResetToken",
+			write: func() error {
+				return repo.SetPasswordResetToken(ctx, user.Email, "reset-token", clock.Now().Add(time.Hour).Unix())
+			},
+			written: func(user *domain.User) bool { return user.PasswordResetToken != nil },
+		},
+		{
+			name:    "ClearPasswordResetToken",
+			write:   func() error { return repo.ClearPasswordResetToken(ctx, id) },
+			written: func(user *domain.User) bool { return user.PasswordResetToken == nil },
+		},
+		{
+			name: "LinkExternalIdentity",
+			write: func() error {
+				return repo.LinkExternalIdentity(ctx, id, domain.ExternalIdentity{Provider: "google", Subject: "google-subject"})
+			},
+			written: func(user *domain.User) bool { return len(user.ExternalIdentities) == 1 && user.EmailVerified },
+		},
+	}
+
+	for _, w := range writes {
+		before, err := repo.GetCurrentByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetCurrentByID() error = %v", err)
+		}
+		if err := w.write(); err != nil {
+			t.Fatalf("%s() error = %v", w.name, err)
+		}
+
+		before.FirstName = "Ayunda " + w.name
+		if err := repo.Update(ctx, before); err != nil {
+			t.Fatalf("Update(before %s) error = %v", w.name, err)
+		}
+
+		after, err := repo.GetCurrentByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetCurrentByID() error = %v", err)
+		}
+		if after.Version != before.Version {
+			t.Errorf("version after %s() and Update() = %d, want %d", w.name, after.Version, before.Version)
+		}
+		if after.FirstName != before.FirstName {
+			t.Errorf("FirstName after %s() = %q, want %q", w.name, after.FirstName, before.FirstName)
+		}
+		if !w.written(after) {
+			t.Errorf("Update() overwrote what %s() wrote: %+v", w.name, after)
+		}
+	}
+}
+
+func testUserUpdateLastLogin(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	clock.Advance(time.Hour)
+	if err := repo.UpdateLastLogin(ctx, user.ID.Hex()); err != nil {
+		t.Fatalf("UpdateLastLogin() error = %v", err)
+	}
+
+	stored, err := repo.GetByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if stored.LastLogin == nil || !stored.LastLogin.Equal(clock.Now()) {
+		t.Errorf("LastLogin = %v, want %v", stored.LastLogin, clock.Now())
+	}
+
+	err = repo.UpdateLastLogin(ctx, primitive.NewObjectID().Hex())
+	assertError(t, "UpdateLastLogin(unknown)", err, domain.ErrUserNotFound)
+}
+
+func testUserDelete(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	assertError(t, "Delete(stale)", repo.Delete(ctx, user.ID.Hex(), user.Version+1), domain.ErrVersionConflict)
+
+	if err := repo.Delete(ctx, user.ID.Hex(), user.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	_, err := repo.GetByID(ctx, user.ID.Hex())
+	assertError(t, "GetByID(deleted)", err, domain.ErrUserNotFound)
+
+	assertError(t, "Delete(deleted)", repo.Delete(ctx, user.ID.Hex(), user.Version), domain.ErrUserNotFound)
+	assertError(t, "Delete(unknown)", repo.Delete(ctx, primitive.NewObjectID().Hex(), 1), domain.ErrUserNotFound)
+	assertError(t, "Delete(invalid)", repo.Delete(ctx, "not-an-id", 1), domain.ErrInvalidID)
+
+	// The email is free again
+	mustCreateUser(t, repo, newUser("ayu@example.com"))
+}
+
+func testUserDeletedHidden(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+	id := user.ID.Hex()
+
+	expiry := clock.Now().Add(time.Hour).Unix()
+	if err := repo.SetPasswordResetToken(ctx, user.Email, "reset-token", expiry); err != nil {
+		t.Fatalf("SetPasswordResetToken() error = %v", err)
+	}
+	identity := domain.ExternalIdentity{Provider: "google", Subject: "google-subject", Email: user.Email}
+	if err := repo.LinkExternalIdentity(ctx, id, identity); err != nil {
+		t.Fatalf("LinkExternalIdentity() error = %v", err)
+	}
+	stale, _ := repo.GetCurrentByID(ctx, id)
+
+	if err := repo.Delete(ctx, id, user.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	_, err := repo.GetByEmail(ctx, user.Email)
+	assertError(t, "GetByEmail(deleted)", err, domain.ErrUserNotFound)
+	_, err = repo.GetByPasswordResetToken(ctx, "reset-token")
+	assertError(t, "GetByPasswordResetToken(deleted)", err, domain.ErrInvalidResetToken)
+	_, err = repo.GetByExternalIdentity(ctx, "google", "google-subject")
+	assertError(t, "GetByExternalIdentity(deleted)", err, domain.ErrUserNotFound)
+
+	assertError(t, "Update(deleted)", repo.Update(ctx, stale), domain.ErrUserNotFound)
+	assertError(t, "UpdateLastLogin(deleted)", repo.UpdateLastLogin(ctx, id), domain.ErrUserNotFound)
+	err = repo.SetPasswordResetToken(ctx, user.Email, "reset-token", expiry)
+	assertError(t, "SetPasswordResetToken(deleted)", err, domain.ErrUserNotFound)
+	assertError(t, "ClearPasswordResetToken(deleted)", repo.ClearPasswordResetToken(ctx, id), domain.ErrUserNotFound)
+
+	// The identity is free for another user
+	other := newUser("budi@example.com")
+	mustCreateUser(t, repo, other)
+	if err := repo.LinkExternalIdentity(ctx, other.ID.Hex(), identity); err != nil {
+		t.Errorf("LinkExternalIdentity(identity of deleted user) error = %v", err)
+	}
+}
+
+func testUserRestore(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+	id := user.ID.Hex()
+
+	if err := repo.Delete(ctx, id, user.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	clock.Advance(time.Hour)
+	if err := repo.Restore(ctx, id); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	restored, err := repo.GetByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatalf("GetByEmail(restored) error = %v", err)
+	}
+	if restored.ID != user.ID || restored.DeletedAt != nil || !restored.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("restored user = %+v, want the live user updated at %v", restored, clock.Now())
+	}
+	if restored.Version != user.Version+2 {
+		t.Errorf("Version = %d, want %d after a delete and a restore", restored.Version, user.Version+2)
+	}
+
+	assertError(t, "Restore(live)", repo.Restore(ctx, id), domain.ErrUserNotFound)
+	assertError(t, "Restore(unknown)", repo.Restore(ctx, primitive.NewObjectID().Hex()), domain.ErrUserNotFound)
+	assertError(t, "Restore(invalid)", repo.Restore(ctx, "not-an-id"), domain.ErrInvalidID)
+}
+
+func testUserRestoreTakenEmail(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	if err := repo.Delete(ctx, user.ID.Hex(), user.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	mustCreateUser(t, repo, newUser("ayu@example.com"))
+
+	assertError(t, "Restore()", repo.Restore(ctx, user.ID.Hex()), domain.ErrUserExists)
+}
+
+func testUserPurge(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	old := newUser("ayu@example.com")
+	recent := newUser("budi@example.com")
+	kept := newUser("citra@example.com")
+	for _, user := range []*domain.User{old, recent, kept} {
+		mustCreateUser(t, repo, user)
+	}
+
+	if err := repo.Delete(ctx, old.ID.Hex(), old.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	clock.Advance(time.Hour)
+	cutoff := clock.Now()
+	if err := repo.Delete(ctx, recent.ID.Hex(), recent.Version); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	ids, err := repo.ListDeletedBefore(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("ListDeletedBefore() error = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != old.ID.Hex() {
+		t.Errorf("ListDeletedBefore() = %v, want [%s]", ids, old.ID.Hex())
+	}
+
+	purged, err := repo.PurgeDeletedBefore(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("PurgeDeletedBefore() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeDeletedBefore() = %d, want 1", purged)
+	}
+
+	// Purging again finds nothing new
+	ids, err = repo.ListDeletedBefore(ctx, cutoff)
+	if err != nil || len(ids) != 0 {
+		t.Errorf("ListDeletedBefore() after purging = %v, %v, want [], nil", ids, err)
+	}
+	purged, err = repo.PurgeDeletedBefore(ctx, cutoff)
+	if err != nil || purged != 0 {
+		t.Errorf("second PurgeDeletedBefore() = %d, %v, want 0, nil", purged, err)
+	}
+
+	assertError(t, "Restore(purged)", repo.Restore(ctx, old.ID.Hex()), domain.ErrUserNotFound)
+	if err := repo.Restore(ctx, recent.ID.Hex()); err != nil {
+		t.Errorf("Restore(deleted after cutoff) error = %v", err)
+	}
+	if _, err := repo.GetByID(ctx, kept.ID.Hex()); err != nil {
+		t.Errorf("GetByID(live) error = %v", err)
+	}
+
+	// The purged user's email is free
+	mustCreateUser(t, repo, newUser("ayu@example.com"))
+}
+
+func testUserPasswordResetExpiry(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	expiry := clock.Now().Add(time.Hour).Unix()
+	if err := repo.SetPasswordResetToken(ctx, user.Email, "reset-token", expiry); err != nil {
+		t.Fatalf("SetPasswordResetToken() error = %v", err)
+	}
+
+	stored, err := repo.GetByPasswordResetToken(ctx, "reset-token")
+	if err != nil {
+		t.Fatalf("GetByPasswordResetToken() error = %v", err)
+	}
+	if stored.ID != user.ID {
+		t.Errorf("GetByPasswordResetToken() = user %s, want %s", stored.ID.Hex(), user.ID.Hex())
+	}
+
+	// GetByID leaves out the pending token, so that it can be cached
+	byID, err := repo.GetByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if byID.PasswordResetToken != nil || byID.PasswordResetExpiry != nil {
+		t.Errorf("GetByID() reset token = %v, %v, want none", byID.PasswordResetToken, byID.PasswordResetExpiry)
+	}
+
+	_, err = repo.GetByPasswordResetToken(ctx, "other-token")
+	assertError(t, "GetByPasswordResetToken(unknown)", err, domain.ErrInvalidResetToken)
+
+	clock.Set(time.Unix(expiry, 0))
+	_, err = repo.GetByPasswordResetToken(ctx, "reset-token")
+	assertError(t, "GetByPasswordResetToken(expired)", err, domain.ErrInvalidResetToken)
+
+	err = repo.SetPasswordResetToken(ctx, "nobody@example.com", "reset-token", expiry)
+	assertError(t, "SetPasswordResetToken(unknown)", err, domain.ErrUserNotFound)
+}
+
+func testUserPasswordResetClear(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	expiry := clock.Now().Add(time.Hour).Unix()
+	if err := repo.SetPasswordResetToken(ctx, user.Email, "reset-token", expiry); err != nil {
+		t.Fatalf("SetPasswordResetToken() error = %v", err)
+	}
+	if err := repo.ClearPasswordResetToken(ctx, user.ID.Hex()); err != nil {
+		t.Fatalf("ClearPasswordResetToken() error = %v", err)
+	}
+
+	_, err := repo.GetByPasswordResetToken(ctx, "reset-token")
+	assertError(t, "GetByPasswordResetToken(cleared)", err, domain.ErrInvalidResetToken)
+
+	stored, err := repo.GetCurrentByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetCurrentByID() error = %v", err)
+	}
+	if stored.PasswordResetToken != nil || stored.PasswordResetExpiry != nil {
+		t.Errorf("reset token = %v, %v, want none", stored.PasswordResetToken, stored.PasswordResetExpiry)
+	}
+
+	err = repo.ClearPasswordResetToken(ctx, primitive.NewObjectID().Hex())
+	assertError(t, "ClearPasswordResetToken(unknown)", err, domain.ErrUserNotFound)
+}
+
+func testUserLinkExternalIdentity(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	ctx := context.Background()
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	identity := domain.ExternalIdentity{Provider: "google", Subject: "google-subject", Email: user.Email, LinkedAt: clock.Now()}
+	if err := repo.LinkExternalIdentity(ctx, user.ID.Hex(), identity); err != nil {
+		t.Fatalf("LinkExternalIdentity() error = %v", err)
+	}
+
+	linked, err := repo.GetByExternalIdentity(ctx, "google", "google-subject")
+	if err != nil {
+		t.Fatalf("GetByExternalIdentity() error = %v", err)
+	}
+	if linked.ID != user.ID || !linked.EmailVerified || len(linked.ExternalIdentities) != 1 {
+		t.Errorf("GetByExternalIdentity() = %+v, want the verified user with one identity", linked)
+	}
+
+	_, err = repo.GetByExternalIdentity(ctx, "google", "other-subject")
+	assertError(t, "GetByExternalIdentity(other subject)", err, domain.ErrUserNotFound)
+
+	// A user links at most one account per provider
+	identity.Subject = "second-google-subject"
+	err = repo.LinkExternalIdentity(ctx, user.ID.Hex(), identity)
+	assertError(t, "LinkExternalIdentity(same provider)", err, domain.ErrIdentityLinked)
+
+	err = repo.LinkExternalIdentity(ctx, primitive.NewObjectID().Hex(), identity)
+	assertError(t, "LinkExternalIdentity(unknown)", err, domain.ErrIdentityLinked)
+}
+
+func testUserConcurrentCreate(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	errs := concurrently(func() error {
+		return repo.Create(context.Background(), newUser("ayu@example.com"))
+	})
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, domain.ErrUserExists):
+			t.Errorf("Create() error = %v, want nil or %v", err, domain.ErrUserExists)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d concurrent creates succeeded, want 1", created)
+	}
+}
+
+func testUserConcurrentDelete(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	errs := concurrently(func() error {
+		return repo.Delete(context.Background(), user.ID.Hex(), user.Version)
+	})
+
+	deleted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			deleted++
+		case !errors.Is(err, domain.ErrUserNotFound):
+			t.Errorf("Delete() error = %v, want nil or %v", err, domain.ErrUserNotFound)
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("%d concurrent deletes succeeded, want 1", deleted)
+	}
+}
+
+func testUserConcurrentUpdate(t *testing.T, repo repository.UserRepository, clock *clock.Fake) {
+	user := newUser("ayu@example.com")
+	mustCreateUser(t, repo, user)
+
+	errs := concurrently(func() error {
+		edit := *user
+		edit.FirstName = "Ayunda"
+		return repo.Update(context.Background(), &edit)
+	})
+
+	updated := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			updated++
+		case !errors.Is(err, domain.ErrVersionConflict):
+			t.Errorf("Update() error = %v, want nil or %v", err, domain.ErrVersionConflict)
+		}
+	}
+	if updated != 1 {
+		t.Errorf("%d concurrent updates of the same version succeeded, want 1", updated)
+	}
+}
+
+// newUser returns a user ready to be created
+func newUser(email string) *domain.User {
+	return &domain.User{
+		Email:     email,
+		Password:  "$2a$10$hash",
+		FirstName: "Ayu",
+		LastName:  "Lestari",
+		Role:      domain.RoleTherapist,
+	}
+}
+
+func mustCreateUser(t *testing.T, repo repository.UserRepository, user *domain.User) {
+	t.Helper()
+
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create(%s) error = %v", user.Email, err)
+	}
+}
+
+// assertUser compares the fields a repository must round-trip
+func assertUser(t *testing.T, got, want *domain.User) {
+	t.Helper()
+
+	if got.ID != want.ID || got.Email != want.Email || got.Password != want.Password ||
+		got.FirstName != want.FirstName || got.LastName != want.LastName || got.Role != want.Role ||
+		got.IsActive != want.IsActive || got.EmailVerified != want.EmailVerified ||
+		!got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) || got.Version != want.Version {
+		t.Errorf("user = %+v, want %+v", got, want)
+	}
+}
+
+// concurrentCalls is how many goroutines the concurrency tests race
+const concurrentCalls = 8
+
+// concurrently calls fn from concurrentCalls goroutines at once and returns
+// their errors
+func concurrently(fn func() error) []error {
+	errs := make([]error, concurrentCalls)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = fn()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+func assertError(t *testing.T, call string, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Errorf("%s error = %v, want %v", call, err, want)
+	}
+}
+
+// newClock returns a fake clock set to the current time, so that backends
+// with server-side expiry such as Redis TTLs see realistic timestamps. It is
+// truncated to the millisecond precision MongoDB stores.
+func newClock() *clock.Fake {
+	return clock.NewFake(time.Now().Truncate(time.Millisecond))
+}
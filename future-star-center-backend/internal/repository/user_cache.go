@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UserCache keeps users by ID for NewCachedUserRepository
+type UserCache interface {
+	// Get returns the cached user, or nil when it is not cached
+	Get(ctx context.Context, id string) (*domain.User, error)
+	Set(ctx context.Context, user *domain.User) error
+	Delete(ctx context.Context, id string) error
+}
+
+type lruUserCache struct {
+	clock clock.Clock
+	ttl   time.Duration
+	size  int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used
+	recent *list.List
+}
+
+type lruEntry struct {
+	user      *domain.User
+	expiresAt time.Time
+}
+
+// NewLRUUserCache creates an in-process user cache holding up to size users
+// for ttl each, evicting the least recently used user when full. Each
+// replica has its own cache, so a change made through another replica shows
+// up once the cached copy expires.
+func NewLRUUserCache(size int, ttl time.Duration, clock clock.Clock) UserCache {
+	return &lruUserCache{
+		clock:   clock,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+func (c *lruUserCache) Get(ctx context.Context, id string) (*domain.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+	if !ok {
+		return nil, nil
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.removeLocked(element)
+		return nil, nil
+	}
+
+	c.recent.MoveToFront(element)
+	return copyUser(entry.user), nil
+}
+
+func (c *lruUserCache) Set(ctx context.Context, user *domain.User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := user.ID.Hex()
+	entry := &lruEntry{user: copyUser(user), expiresAt: c.clock.Now().Add(c.ttl)}
+
+	if element, ok := c.entries[id]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[id] = c.recent.PushFront(entry)
+	if c.recent.Len() > c.size {
+		c.removeLocked(c.recent.Back())
+	}
+	return nil
+}
+
+func (c *lruUserCache) Delete(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.removeLocked(element)
+	}
+	return nil
+}
+
+// removeLocked drops an entry. The caller must hold the lock.
+func (c *lruUserCache) removeLocked(element *list.Element) {
+	c.recent.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).user.ID.Hex())
+}
+
+type redisUserCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// NewRedisUserCache creates a user cache shared by all replicas, keeping
+// each user under user_cache:<id> for ttl. Users are stored as BSON, which
+// unlike their JSON form includes every field; they come from GetByID, so
+// those fields never include credentials. Reading a user through this cache
+// is a Redis call of its own, on top of reading the session.
+func NewRedisUserCache(client redis.UniversalClient, ttl time.Duration) UserCache {
+	return &redisUserCache{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (c *redisUserCache) Get(ctx context.Context, id string) (*domain.User, error) {
+	data, err := c.client.Get(ctx, userCacheKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var user domain.User
+	if err := bson.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *redisUserCache) Set(ctx context.Context, user *domain.User) error {
+	data, err := bson.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, userCacheKey(user.ID.Hex()), data, c.ttl).Err()
+}
+
+func (c *redisUserCache) Delete(ctx context.Context, id string) error {
+	return c.client.Del(ctx, userCacheKey(id)).Err()
+}
+
+func userCacheKey(id string) string {
+	return fmt.Sprintf("user_cache:%s", id)
+}
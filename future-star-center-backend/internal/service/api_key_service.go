@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/pkg/utils"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	logger     *slog.Logger
+	clock      clock.Clock
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, logger *slog.Logger, clock clock.Clock) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		logger:     logger,
+		clock:      clock,
+	}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, req CreateAPIKeyRequest, createdBy string) (*CreateAPIKeyResponse, error) {
+	// Validate scopes
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, domain.NewValidationError(fmt.Sprintf("invalid scope: %s", scope))
+		}
+	}
+
+	key := &domain.APIKey{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		CreatedBy: createdBy,
+	}
+
+	if req.ExpiresAt != nil {
+		expiresAt := time.Unix(*req.ExpiresAt, 0)
+		if !expiresAt.After(s.clock.Now()) {
+			return nil, domain.NewValidationError("expiry must be in the future")
+		}
+		key.ExpiresAt = &expiresAt
+	}
+
+	// The key is "fsc_<prefix>_<secret>"; the prefix is stored in clear for
+	// lookup and display, the full key only as a hash
+	prefix, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	rawKey := domain.APIKeyPrefix + prefix + "_" + secret
+	key.Prefix = prefix
+	key.KeyHash = utils.HashToken(rawKey)
+
+	err = s.apiKeyRepo.Create(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return &CreateAPIKeyResponse{
+		APIKey: key,
+		Key:    rawKey,
+	}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.apiKeyRepo.List(ctx)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id string) error {
+	return s.apiKeyRepo.Revoke(ctx, id)
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	prefix, ok := parseAPIKeyPrefix(rawKey)
+	if !ok {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, prefix)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(rawKey))) != 1 {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	if !key.IsActive(s.clock.Now()) {
+		return nil, domain.ErrAPIKeyRevoked
+	}
+
+	// Track usage
+	err = s.apiKeyRepo.UpdateLastUsed(ctx, key.ID.Hex())
+	if err != nil {
+		// Log error but don't fail the request
+		s.logger.ErrorContext(ctx, "failed to update API key last used", "api_key_prefix", key.Prefix, "error", err)
+	}
+
+	return key, nil
+}
+
+// parseAPIKeyPrefix extracts the lookup prefix from a raw API key
+func parseAPIKeyPrefix(rawKey string) (string, bool) {
+	rest, ok := strings.CutPrefix(rawKey, domain.APIKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
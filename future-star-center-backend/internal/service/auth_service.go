@@ -2,50 +2,84 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"future-star-center-backend/internal/clock"
 	"future-star-center-backend/internal/config"
 	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/mailer"
+	"future-star-center-backend/internal/metrics"
 	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/tracing"
 	"future-star-center-backend/pkg/utils"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// maxLoginChallengeAttempts is the number of codes that can be tried before a
+// step-up challenge is discarded
+const maxLoginChallengeAttempts = 5
+
 type authService struct {
-	userRepo    repository.UserRepository
-	sessionRepo repository.SessionRepository
-	config      *config.Config
+	userRepo      repository.UserRepository
+	sessionRepo   repository.SessionRepository
+	challengeRepo repository.LoginChallengeRepository
+	loginRisk     LoginRiskService
+	mailer        mailer.Mailer
+	config        *config.Config
+	logger        *slog.Logger
+	metrics       *metrics.Metrics
+	clock         clock.Clock
 }
 
 // NewAuthService creates a new authentication service
 func NewAuthService(
 	userRepo repository.UserRepository,
 	sessionRepo repository.SessionRepository,
+	challengeRepo repository.LoginChallengeRepository,
+	loginRisk LoginRiskService,
+	mailer mailer.Mailer,
 	config *config.Config,
+	logger *slog.Logger,
+	metrics *metrics.Metrics,
+	clock clock.Clock,
 ) AuthService {
 	return &authService{
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
-		config:      config,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		challengeRepo: challengeRepo,
+		loginRisk:     loginRisk,
+		mailer:        mailer,
+		config:        config,
+		logger:        logger,
+		metrics:       metrics,
+		clock:         clock,
 	}
 }
 
-func (s *authService) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+func (s *authService) Register(ctx context.Context, req RegisterRequest) (resp *AuthResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Register")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// Check if user already exists
-	_, err := s.userRepo.GetByEmail(ctx, req.Email)
+	_, err = s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil {
-		return nil, errors.New("user with this email already exists")
+		return nil, domain.ErrUserExists
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
 	}
 
 	// Validate role
 	if !req.Role.IsValid() {
-		return nil, errors.New("invalid role")
+		return nil, domain.ErrInvalidRole
 	}
 
 	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	hashedPassword, err := hashPassword(ctx, req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -64,99 +98,210 @@ func (s *authService) Register(ctx context.Context, req RegisterRequest) (*AuthR
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user, s.config.JWT.Secret, s.config.JWT.ExpiresIn)
+	return newAuthResponse(ctx, s.sessionRepo, s.config, s.clock.Now(), user)
+}
+
+func (s *authService) Login(ctx context.Context, req LoginRequest) (resp *AuthResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	resp, err = s.login(ctx, req)
+	s.recordLogin("password", resp, err)
+	return resp, err
+}
+
+func (s *authService) login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+	// Get user by email
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Create session
-	session := &domain.Session{
-		ID:        uuid.New().String(),
-		UserID:    user.ID.Hex(),
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(s.config.Session.ExpiresIn),
+	// Check if user is active
+	if !user.IsActive {
+		return nil, domain.ErrAccountDeactivated
+	}
+
+	// Check password
+	if !checkPassword(ctx, req.Password, user.Password) {
+		return nil, domain.ErrInvalidCredentials
 	}
 
-	err = s.sessionRepo.Create(ctx, session)
+	// Compare the login against the user's recent history
+	event, err := s.loginRisk.Assess(ctx, user, req.IPAddress, req.UserAgent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		s.logger.ErrorContext(ctx, "failed to assess login", "user_id", user.ID.Hex(), "error", err)
+
+		// A login that could not be assessed is treated as flagged, and its
+		// event is never recorded: its device and network would otherwise
+		// count as seen and go unflagged on later logins
+		if s.config.LoginRisk.StepUpEnabled {
+			return s.createLoginChallenge(ctx, user, nil)
+		}
+		return s.completeLogin(ctx, user, nil)
 	}
 
-	return &AuthResponse{
-		User:      ToUserResponse(user),
-		Token:     token,
-		SessionID: session.ID,
-		ExpiresAt: session.ExpiresAt.Unix(),
-	}, nil
+	// Flagged logins must be confirmed with an emailed code
+	if event.IsSuspicious() && s.config.LoginRisk.StepUpEnabled {
+		return s.createLoginChallenge(ctx, user, event)
+	}
+
+	return s.completeLogin(ctx, user, event)
 }
 
-func (s *authService) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
-	// Get user by email
-	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+func (s *authService) VerifyLogin(ctx context.Context, req VerifyLoginRequest) (resp *AuthResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.VerifyLogin")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	resp, err = s.verifyLogin(ctx, req)
+	s.recordLogin("step_up", resp, err)
+	return resp, err
+}
+
+func (s *authService) verifyLogin(ctx context.Context, req VerifyLoginRequest) (*AuthResponse, error) {
+	// Every attempt is counted before the code is checked, so that parallel
+	// guesses cannot exceed the limit
+	attempts, err := s.challengeRepo.RecordAttempt(ctx, req.ChallengeID)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, err
+	}
+	if attempts > maxLoginChallengeAttempts {
+		s.discardLoginChallenge(ctx, req.ChallengeID)
+		return nil, domain.ErrInvalidLoginCode
 	}
 
-	// Check if user is active
-	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+	challenge, err := s.challengeRepo.Get(ctx, req.ChallengeID)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check password
-	if !utils.CheckPassword(req.Password, user.Password) {
-		return nil, errors.New("invalid email or password")
+	if subtle.ConstantTimeCompare([]byte(challenge.CodeHash), []byte(utils.HashToken(req.Code))) != 1 {
+		if attempts >= maxLoginChallengeAttempts {
+			s.discardLoginChallenge(ctx, challenge.ID)
+		}
+		return nil, domain.ErrInvalidLoginCode
 	}
 
-	// Update last login
-	err = s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
+	// Codes are single use: of parallel requests with the code, only the one
+	// consuming the challenge logs in
+	err = s.challengeRepo.Consume(ctx, challenge.ID)
 	if err != nil {
-		// Log error but don't fail the login
-		fmt.Printf("Failed to update last login for user %s: %v\n", user.ID.Hex(), err)
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user, s.config.JWT.Secret, s.config.JWT.ExpiresIn)
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidLoginCode
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Create session
-	session := &domain.Session{
+	// Check if user is still active
+	if !user.IsActive {
+		return nil, domain.ErrAccountDeactivated
+	}
+
+	return s.completeLogin(ctx, user, challenge.Event)
+}
+
+// discardLoginChallenge deletes a challenge that has run out of attempts
+func (s *authService) discardLoginChallenge(ctx context.Context, id string) {
+	if err := s.challengeRepo.Delete(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete login challenge", "challenge_id", id, "error", err)
+	}
+}
+
+// recordLogin counts a login attempt by its outcome
+func (s *authService) recordLogin(method string, resp *AuthResponse, err error) {
+	switch {
+	case err != nil:
+		s.metrics.RecordLogin(method, metrics.LoginFailed)
+	case resp.StepUpRequired:
+		s.metrics.RecordLogin(method, metrics.LoginStepUp)
+	default:
+		s.metrics.RecordLogin(method, metrics.LoginSucceeded)
+	}
+}
+
+// createLoginChallenge emails a verification code for a flagged login
+func (s *authService) createLoginChallenge(ctx context.Context, user *domain.User, event *domain.LoginEvent) (*AuthResponse, error) {
+	code, err := utils.GenerateNumericCode(6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification code: %w", err)
+	}
+
+	challenge := &domain.LoginChallenge{
 		ID:        uuid.New().String(),
 		UserID:    user.ID.Hex(),
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(s.config.Session.ExpiresIn),
+		CodeHash:  utils.HashToken(code),
+		Event:     event,
+		ExpiresAt: s.clock.Now().Add(s.config.LoginRisk.StepUpExpiresIn),
 	}
 
-	err = s.sessionRepo.Create(ctx, session)
+	err = s.challengeRepo.Save(ctx, challenge)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, fmt.Errorf("failed to save login challenge: %w", err)
+	}
+
+	body := fmt.Sprintf("Your Future Star Center verification code is %s. It expires in %s.", code, s.config.LoginRisk.StepUpExpiresIn)
+	err = s.mailer.Send(ctx, user.Email, "Confirm your sign-in", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send verification code: %w", err)
 	}
 
 	return &AuthResponse{
-		User:      ToUserResponse(user),
-		Token:     token,
-		SessionID: session.ID,
-		ExpiresAt: session.ExpiresAt.Unix(),
+		StepUpRequired: true,
+		ChallengeID:    challenge.ID,
+		ExpiresAt:      challenge.ExpiresAt.Unix(),
 	}, nil
 }
 
-func (s *authService) Logout(ctx context.Context, sessionID string) error {
+// completeLogin records the login, unless event is nil, and issues a session
+func (s *authService) completeLogin(ctx context.Context, user *domain.User, event *domain.LoginEvent) (*AuthResponse, error) {
+	if event != nil {
+		err := s.loginRisk.Record(ctx, user, event)
+		if err != nil {
+			// Log error but don't fail the login
+			s.logger.ErrorContext(ctx, "failed to record login", "user_id", user.ID.Hex(), "error", err)
+		}
+	}
+
+	// Update last login
+	err := s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
+	if err != nil {
+		// Log error but don't fail the login
+		s.logger.ErrorContext(ctx, "failed to update last login", "user_id", user.ID.Hex(), "error", err)
+	}
+
+	return newAuthResponse(ctx, s.sessionRepo, s.config, s.clock.Now(), user)
+}
+
+func (s *authService) Logout(ctx context.Context, sessionID string) (err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Logout")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	return s.sessionRepo.Delete(ctx, sessionID)
 }
 
-func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
+func (s *authService) RequestPasswordReset(ctx context.Context, email string) (err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.RequestPasswordReset")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	s.metrics.RecordPasswordResetRequested()
+
 	// Check if user exists
 	user, err := s.userRepo.GetByEmail(ctx, email)
-	if err != nil {
+	if errors.Is(err, domain.ErrNotFound) {
 		// Don't reveal if user exists or not
 		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
 
 	// Generate reset token
 	token, err := utils.GenerateRandomToken(32)
@@ -165,7 +310,7 @@ func (s *authService) RequestPasswordReset(ctx context.Context, email string) er
 	}
 
 	// Set token expiry
-	expiry := time.Now().Add(s.config.Password.ResetExpiresIn).Unix()
+	expiry := s.clock.Now().Add(s.config.Password.ResetExpiresIn).Unix()
 
 	// Save token to database
 	err = s.userRepo.SetPasswordResetToken(ctx, user.Email, token, expiry)
@@ -173,22 +318,28 @@ func (s *authService) RequestPasswordReset(ctx context.Context, email string) er
 		return fmt.Errorf("failed to save reset token: %w", err)
 	}
 
-	// TODO: Send email with reset token
-	// For now, we'll just log it (in production, implement email sending)
-	fmt.Printf("Password reset token for %s: %s\n", email, token)
+	body := fmt.Sprintf("Use this token to reset your Future Star Center password: %s\nIt expires in %s.", token, s.config.Password.ResetExpiresIn)
+	err = s.mailer.Send(ctx, user.Email, "Reset your password", body)
+	if err != nil {
+		// Failing only for existing users would reveal them too
+		s.logger.ErrorContext(ctx, "failed to send reset email", "user_id", user.ID.Hex(), "error", err)
+	}
 
 	return nil
 }
 
-func (s *authService) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+func (s *authService) ResetPassword(ctx context.Context, req ResetPasswordRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ResetPassword")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// Get user by reset token
 	user, err := s.userRepo.GetByPasswordResetToken(ctx, req.Token)
 	if err != nil {
-		return errors.New("invalid or expired reset token")
+		return err
 	}
 
 	// Hash new password
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -210,34 +361,79 @@ func (s *authService) ResetPassword(ctx context.Context, req ResetPasswordReques
 	err = s.sessionRepo.DeleteAllUserSessions(ctx, user.ID.Hex())
 	if err != nil {
 		// Log error but don't fail the operation
-		fmt.Printf("Failed to delete user sessions for %s: %v\n", user.ID.Hex(), err)
+		s.logger.ErrorContext(ctx, "failed to delete user sessions", "user_id", user.ID.Hex(), "error", err)
 	}
 
 	return nil
 }
 
-func (s *authService) GetSession(ctx context.Context, sessionID string) (*domain.Session, error) {
+func (s *authService) GetSession(ctx context.Context, sessionID string) (session *domain.Session, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetSession")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	return s.sessionRepo.Get(ctx, sessionID)
 }
 
-func (s *authService) ValidateSession(ctx context.Context, sessionID string) (*domain.User, error) {
+func (s *authService) ValidateSession(ctx context.Context, sessionID string) (user *domain.User, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ValidateSession")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// Get session
-	println("[DEBUG] Validating session:", sessionID)
 	session, err := s.sessionRepo.Get(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get user
-	user, err := s.userRepo.GetByID(ctx, session.UserID)
+	user, err = s.userRepo.GetByID(ctx, session.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrSessionNotFound
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	// Check if user is still active
 	if !user.IsActive {
-		return nil, errors.New("user account is deactivated")
+		return nil, domain.ErrAccountDeactivated
 	}
 
 	return user, nil
 }
+
+// newAuthResponse generates a JWT and a new session for an authenticated user
+func newAuthResponse(
+	ctx context.Context,
+	sessionRepo repository.SessionRepository,
+	config *config.Config,
+	now time.Time,
+	user *domain.User,
+) (*AuthResponse, error) {
+	// Generate JWT token
+	token, err := utils.GenerateJWT(user, config.JWT.Secret, now, config.JWT.ExpiresIn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	// Create session
+	session := &domain.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		Role:      user.Role,
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.Session.ExpiresIn),
+	}
+
+	err = sessionRepo.Create(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return &AuthResponse{
+		User:      ToUserResponse(user),
+		Token:     token,
+		SessionID: session.ID,
+		ExpiresAt: session.ExpiresAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,755 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct-horse-battery"
+
+// testEnv is an AuthService backed by in-memory repositories and a fake clock
+type testEnv struct {
+	auth       service.AuthService
+	users      repository.UserRepository
+	sessions   repository.SessionRepository
+	challenges repository.LoginChallengeRepository
+	risk       *fakeLoginRisk
+	mailer     *fakeMailer
+	clock      *clock.Fake
+	config     *config.Config
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		risk:   &fakeLoginRisk{},
+		mailer: &fakeMailer{},
+		clock:  clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)),
+		config: config.Default(),
+	}
+	env.config.LoginRisk.StepUpEnabled = true
+	env.users = repository.NewMemoryUserRepository(env.clock)
+	env.sessions = repository.NewMemorySessionRepository(env.clock)
+	env.challenges = repository.NewMemoryLoginChallengeRepository(env.clock)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env.auth = service.NewAuthService(env.users, env.sessions, env.challenges, env.risk, env.mailer, env.config, logger, nil, env.clock)
+	return env
+}
+
+// register creates an active user with testPassword
+func (env *testEnv) register(t *testing.T, email string) *service.AuthResponse {
+	t.Helper()
+
+	resp, err := env.auth.Register(context.Background(), service.RegisterRequest{
+		Email:     email,
+		Password:  testPassword,
+		FirstName: "Ayu",
+		LastName:  "Lestari",
+		Role:      domain.RoleTherapist,
+	})
+	if err != nil {
+		t.Fatalf("Register(%s) error = %v", email, err)
+	}
+	return resp
+}
+
+// deactivate marks a registered user as inactive
+func (env *testEnv) deactivate(t *testing.T, email string) {
+	t.Helper()
+
+	user, err := env.users.GetByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetByEmail(%s) error = %v", email, err)
+	}
+	user.IsActive = false
+	if err := env.users.Update(context.Background(), user); err != nil {
+		t.Fatalf("Update(%s) error = %v", email, err)
+	}
+}
+
+// challenge starts a step-up login and returns the challenge ID and code
+func (env *testEnv) challenge(t *testing.T, email string) (string, string) {
+	t.Helper()
+
+	env.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewDevice}
+	resp, err := env.auth.Login(context.Background(), service.LoginRequest{Email: email, Password: testPassword})
+	env.risk.anomalies = nil
+	if err != nil {
+		t.Fatalf("Login(%s) error = %v", email, err)
+	}
+	if !resp.StepUpRequired {
+		t.Fatalf("Login(%s) did not require step-up", email)
+	}
+	return resp.ChallengeID, env.mailer.match(t, verificationCode)
+}
+
+// requestReset sends a password reset email and returns the token
+func (env *testEnv) requestReset(t *testing.T, email string) string {
+	t.Helper()
+
+	if err := env.auth.RequestPasswordReset(context.Background(), email); err != nil {
+		t.Fatalf("RequestPasswordReset(%s) error = %v", email, err)
+	}
+	return env.mailer.match(t, resetToken)
+}
+
+var (
+	verificationCode = regexp.MustCompile(`code is (\d{6})`)
+	resetToken       = regexp.MustCompile(`password: (\S+)`)
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// fakeMailer records the emails it is asked to send, failing with err when
+// it is set
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func (m *fakeMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+// match returns the first submatch of pattern in the last email sent
+func (m *fakeMailer) match(t *testing.T, pattern *regexp.Regexp) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := pattern.FindStringSubmatch(m.sent[len(m.sent)-1].body)
+	if match == nil {
+		t.Fatalf("last email does not match %s: %q", pattern, m.sent[len(m.sent)-1].body)
+	}
+	return match[1]
+}
+
+// fakeLoginRisk flags logins with the configured anomalies
+type fakeLoginRisk struct {
+	anomalies []domain.LoginAnomaly
+	err       error
+	recorded  int
+}
+
+func (f *fakeLoginRisk) Assess(ctx context.Context, user *domain.User, ipAddress, userAgent string) (*domain.LoginEvent, error) {
+	return &domain.LoginEvent{UserID: user.ID.Hex(), IPAddress: ipAddress, Anomalies: f.anomalies}, f.err
+}
+
+func (f *fakeLoginRisk) Record(ctx context.Context, user *domain.User, event *domain.LoginEvent) error {
+	f.recorded++
+	return nil
+}
+
+// assertError fails unless err matches want, where a nil want expects success
+func assertError(t *testing.T, err, want error) {
+	t.Helper()
+
+	if want == nil {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if !errors.Is(err, want) {
+		t.Fatalf("error = %v, want %v", err, want)
+	}
+}
+
+// assertSession fails unless resp carries a session that is stored
+func assertSession(t *testing.T, env *testEnv, resp *service.AuthResponse) {
+	t.Helper()
+
+	if resp.SessionID == "" || resp.Token == "" || resp.User == nil {
+		t.Fatalf("response lacks a session: %+v", resp)
+	}
+	wantExpiry := env.clock.Now().Add(env.config.Session.ExpiresIn).Unix()
+	if resp.ExpiresAt != wantExpiry {
+		t.Errorf("ExpiresAt = %d, want %d", resp.ExpiresAt, wantExpiry)
+	}
+	if _, err := env.sessions.Get(context.Background(), resp.SessionID); err != nil {
+		t.Errorf("session %s is not stored: %v", resp.SessionID, err)
+	}
+}
+
+func TestAuthServiceRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, env *testEnv)
+		req     service.RegisterRequest
+		wantErr error
+	}{
+		{
+			name: "creates an active user and a session",
+			req:  service.RegisterRequest{Email: "ayu@example.com", Password: testPassword, FirstName: "Ayu", LastName: "Lestari", Role: domain.RoleStaff},
+		},
+		{
+			name:    "rejects a taken email",
+			setup:   func(t *testing.T, env *testEnv) { env.register(t, "ayu@example.com") },
+			req:     service.RegisterRequest{Email: "ayu@example.com", Password: testPassword, FirstName: "Ayu", LastName: "Lestari", Role: domain.RoleStaff},
+			wantErr: domain.ErrUserExists,
+		},
+		{
+			name:    "rejects an unknown role",
+			req:     service.RegisterRequest{Email: "ayu@example.com", Password: testPassword, FirstName: "Ayu", LastName: "Lestari", Role: "owner"},
+			wantErr: domain.ErrInvalidRole,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.setup != nil {
+				tt.setup(t, env)
+			}
+
+			resp, err := env.auth.Register(context.Background(), tt.req)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			assertSession(t, env, resp)
+			user, err := env.users.GetByEmail(context.Background(), tt.req.Email)
+			if err != nil {
+				t.Fatalf("user was not stored: %v", err)
+			}
+			if !user.IsActive || user.Role != tt.req.Role || user.Password == tt.req.Password {
+				t.Errorf("stored user = %+v", user)
+			}
+		})
+	}
+}
+
+func TestAuthServiceLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, env *testEnv)
+		req         service.LoginRequest
+		wantErr     error
+		wantStepUp  bool
+		wantSession bool
+		// wantRecorded is the number of login events recorded
+		wantRecorded int
+	}{
+		{
+			name:         "issues a session for valid credentials",
+			req:          service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantSession:  true,
+			wantRecorded: 1,
+		},
+		{
+			name:    "rejects an unknown email",
+			req:     service.LoginRequest{Email: "nobody@example.com", Password: testPassword},
+			wantErr: domain.ErrInvalidCredentials,
+		},
+		{
+			name:    "rejects a wrong password",
+			req:     service.LoginRequest{Email: "ayu@example.com", Password: "wrong-password"},
+			wantErr: domain.ErrInvalidCredentials,
+		},
+		{
+			name:    "rejects a deactivated account",
+			setup:   func(t *testing.T, env *testEnv) { env.deactivate(t, "ayu@example.com") },
+			req:     service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantErr: domain.ErrAccountDeactivated,
+		},
+		{
+			name:       "requires step-up for a suspicious login",
+			setup:      func(t *testing.T, env *testEnv) { env.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewNetwork} },
+			req:        service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantStepUp: true,
+		},
+		{
+			name: "issues a session for a suspicious login when step-up is disabled",
+			setup: func(t *testing.T, env *testEnv) {
+				env.risk.anomalies = []domain.LoginAnomaly{domain.AnomalyNewNetwork}
+				env.config.LoginRisk.StepUpEnabled = false
+			},
+			req:          service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantSession:  true,
+			wantRecorded: 1,
+		},
+		{
+			name:       "requires step-up when the risk assessment fails",
+			setup:      func(t *testing.T, env *testEnv) { env.risk.err = errors.New("login history unavailable") },
+			req:        service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantStepUp: true,
+		},
+		{
+			name: "issues a session without recording it when the risk assessment fails and step-up is disabled",
+			setup: func(t *testing.T, env *testEnv) {
+				env.risk.err = errors.New("login history unavailable")
+				env.config.LoginRisk.StepUpEnabled = false
+			},
+			req:         service.LoginRequest{Email: "ayu@example.com", Password: testPassword},
+			wantSession: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.register(t, "ayu@example.com")
+			if tt.setup != nil {
+				tt.setup(t, env)
+			}
+			env.clock.Advance(time.Minute)
+
+			resp, err := env.auth.Login(context.Background(), tt.req)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tt.wantSession {
+				assertSession(t, env, resp)
+				user, _ := env.users.GetByEmail(context.Background(), tt.req.Email)
+				if user.LastLogin == nil || !user.LastLogin.Equal(env.clock.Now()) {
+					t.Errorf("LastLogin = %v, want %v", user.LastLogin, env.clock.Now())
+				}
+			}
+
+			if tt.wantStepUp {
+				if !resp.StepUpRequired || resp.ChallengeID == "" || resp.SessionID != "" {
+					t.Fatalf("response = %+v, want a step-up challenge", resp)
+				}
+				wantExpiry := env.clock.Now().Add(env.config.LoginRisk.StepUpExpiresIn).Unix()
+				if resp.ExpiresAt != wantExpiry {
+					t.Errorf("ExpiresAt = %d, want %d", resp.ExpiresAt, wantExpiry)
+				}
+				env.mailer.match(t, verificationCode)
+			}
+
+			if env.risk.recorded != tt.wantRecorded {
+				t.Errorf("recorded %d login events, want %d", env.risk.recorded, tt.wantRecorded)
+			}
+		})
+	}
+}
+
+func TestAuthServiceVerifyLoginAfterFailedAssessment(t *testing.T) {
+	env := newTestEnv(t)
+	env.register(t, "ayu@example.com")
+
+	env.risk.err = errors.New("login history unavailable")
+	challengeID, code := env.challenge(t, "ayu@example.com")
+	env.risk.err = nil
+
+	resp, err := env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+	assertError(t, err, nil)
+	assertSession(t, env, resp)
+
+	// The login was never assessed, so it must not become part of the history
+	if env.risk.recorded != 0 {
+		t.Errorf("recorded %d login events, want 0", env.risk.recorded)
+	}
+}
+
+func TestAuthServiceVerifyLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		// run verifies the challenge, returning the response and error of the
+		// last attempt
+		run     func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error)
+		wantErr error
+	}{
+		{
+			name: "issues a session for the emailed code",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+		},
+		{
+			name: "rejects a wrong code",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: "000000"})
+			},
+			wantErr: domain.ErrInvalidLoginCode,
+		},
+		{
+			name: "accepts the code after a wrong attempt",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: "000000"})
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+		},
+		{
+			name: "discards the challenge after too many wrong codes",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				for i := 0; i < 5; i++ {
+					env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: "000000"})
+				}
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+			wantErr: domain.ErrInvalidLoginCode,
+		},
+		{
+			name: "rejects a code that was already used",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				if _, err := env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code}); err != nil {
+					t.Fatalf("first VerifyLogin error = %v", err)
+				}
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+			wantErr: domain.ErrInvalidLoginCode,
+		},
+		{
+			name: "rejects an expired challenge",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				env.clock.Advance(env.config.LoginRisk.StepUpExpiresIn)
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+			wantErr: domain.ErrInvalidLoginCode,
+		},
+		{
+			name: "rejects an unknown challenge",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: "unknown", Code: code})
+			},
+			wantErr: domain.ErrInvalidLoginCode,
+		},
+		{
+			name: "rejects a user deactivated since the login",
+			run: func(t *testing.T, env *testEnv, challengeID, code string) (*service.AuthResponse, error) {
+				env.deactivate(t, "ayu@example.com")
+				return env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: code})
+			},
+			wantErr: domain.ErrAccountDeactivated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.register(t, "ayu@example.com")
+			challengeID, code := env.challenge(t, "ayu@example.com")
+
+			resp, err := tt.run(t, env, challengeID, code)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			assertSession(t, env, resp)
+			if env.risk.recorded != 1 {
+				t.Errorf("recorded %d login events, want 1", env.risk.recorded)
+			}
+		})
+	}
+}
+
+func TestAuthServiceVerifyLoginConcurrently(t *testing.T) {
+	tests := []struct {
+		name string
+		// codes returns the code each parallel request sends
+		codes      func(code string) []string
+		wantLogins int
+	}{
+		{
+			name: "logs in once for parallel requests with the code",
+			codes: func(code string) []string {
+				return []string{code, code, code, code}
+			},
+			wantLogins: 1,
+		},
+		{
+			name: "limits parallel guesses to the allowed attempts",
+			codes: func(code string) []string {
+				codes := make([]string, 20)
+				for i := range codes {
+					codes[i] = "000000"
+				}
+				// Arrives after the guesses have used up the attempts
+				return append(codes, code)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.register(t, "ayu@example.com")
+			challengeID, code := env.challenge(t, "ayu@example.com")
+			codes := tt.codes(code)
+
+			var wg sync.WaitGroup
+			errs := make([]error, len(codes)-1)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: codes[i]})
+				}()
+			}
+			wg.Wait()
+			_, last := env.auth.VerifyLogin(context.Background(), service.VerifyLoginRequest{ChallengeID: challengeID, Code: codes[len(codes)-1]})
+
+			logins := 0
+			for _, err := range append(errs, last) {
+				switch {
+				case err == nil:
+					logins++
+				case !errors.Is(err, domain.ErrInvalidLoginCode):
+					t.Errorf("VerifyLogin() error = %v, want %v", err, domain.ErrInvalidLoginCode)
+				}
+			}
+			if logins != tt.wantLogins {
+				t.Errorf("%d requests logged in, want %d", logins, tt.wantLogins)
+			}
+		})
+	}
+}
+
+func TestAuthServiceLogout(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID func(resp *service.AuthResponse) string
+	}{
+		{name: "deletes the session", sessionID: func(resp *service.AuthResponse) string { return resp.SessionID }},
+		{name: "ignores an unknown session", sessionID: func(resp *service.AuthResponse) string { return "unknown" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			resp := env.register(t, "ayu@example.com")
+
+			err := env.auth.Logout(context.Background(), tt.sessionID(resp))
+			assertError(t, err, nil)
+
+			_, err = env.sessions.Get(context.Background(), tt.sessionID(resp))
+			assertError(t, err, domain.ErrSessionNotFound)
+		})
+	}
+}
+
+func TestAuthServiceRequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		mailErr   error
+		wantEmail bool
+	}{
+		{name: "emails a reset token to a known user", email: "ayu@example.com", wantEmail: true},
+		{name: "succeeds silently for an unknown email", email: "nobody@example.com"},
+		{name: "succeeds silently when the email cannot be sent", email: "ayu@example.com", mailErr: errors.New("smtp unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.register(t, "ayu@example.com")
+			env.mailer.err = tt.mailErr
+
+			err := env.auth.RequestPasswordReset(context.Background(), tt.email)
+			assertError(t, err, nil)
+
+			if !tt.wantEmail {
+				if env.mailer.count() != 0 {
+					t.Fatalf("sent %d emails, want none", env.mailer.count())
+				}
+				return
+			}
+
+			token := env.mailer.match(t, resetToken)
+			user, err := env.users.GetByPasswordResetToken(context.Background(), token)
+			if err != nil || user.Email != tt.email {
+				t.Fatalf("GetByPasswordResetToken() = %v, %v", user, err)
+			}
+			wantExpiry := env.clock.Now().Add(env.config.Password.ResetExpiresIn)
+			if !user.PasswordResetExpiry.Equal(wantExpiry) {
+				t.Errorf("PasswordResetExpiry = %v, want %v", user.PasswordResetExpiry, wantExpiry)
+			}
+		})
+	}
+}
+
+func TestAuthServiceResetPassword(t *testing.T) {
+	const newPassword = "new-correct-horse"
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T, env *testEnv) string
+		wantErr error
+	}{
+		{
+			name:  "sets the new password with a valid token",
+			token: func(t *testing.T, env *testEnv) string { return env.requestReset(t, "ayu@example.com") },
+		},
+		{
+			name: "rejects an expired token",
+			token: func(t *testing.T, env *testEnv) string {
+				token := env.requestReset(t, "ayu@example.com")
+				env.clock.Advance(env.config.Password.ResetExpiresIn)
+				return token
+			},
+			wantErr: domain.ErrInvalidResetToken,
+		},
+		{
+			name: "rejects a token that was already used",
+			token: func(t *testing.T, env *testEnv) string {
+				token := env.requestReset(t, "ayu@example.com")
+				if err := env.auth.ResetPassword(context.Background(), service.ResetPasswordRequest{Token: token, NewPassword: newPassword}); err != nil {
+					t.Fatalf("first ResetPassword error = %v", err)
+				}
+				return token
+			},
+			wantErr: domain.ErrInvalidResetToken,
+		},
+		{
+			name:    "rejects an unknown token",
+			token:   func(t *testing.T, env *testEnv) string { return "unknown" },
+			wantErr: domain.ErrInvalidResetToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			session := env.register(t, "ayu@example.com")
+			token := tt.token(t, env)
+
+			err := env.auth.ResetPassword(context.Background(), service.ResetPasswordRequest{Token: token, NewPassword: newPassword})
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			// Existing sessions are ended and only the new password works
+			_, err = env.sessions.Get(context.Background(), session.SessionID)
+			assertError(t, err, domain.ErrSessionNotFound)
+
+			_, err = env.auth.Login(context.Background(), service.LoginRequest{Email: "ayu@example.com", Password: testPassword})
+			assertError(t, err, domain.ErrInvalidCredentials)
+
+			_, err = env.auth.Login(context.Background(), service.LoginRequest{Email: "ayu@example.com", Password: newPassword})
+			assertError(t, err, nil)
+		})
+	}
+}
+
+func TestAuthServiceGetSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(env *testEnv)
+		unknown bool
+		wantErr error
+	}{
+		{name: "returns a live session"},
+		{
+			name:  "returns a session just before it expires",
+			setup: func(env *testEnv) { env.clock.Advance(env.config.Session.ExpiresIn - time.Second) },
+		},
+		{
+			name:    "rejects an expired session",
+			setup:   func(env *testEnv) { env.clock.Advance(env.config.Session.ExpiresIn) },
+			wantErr: domain.ErrSessionNotFound,
+		},
+		{name: "rejects an unknown session", unknown: true, wantErr: domain.ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			resp := env.register(t, "ayu@example.com")
+			if tt.setup != nil {
+				tt.setup(env)
+			}
+
+			sessionID := resp.SessionID
+			if tt.unknown {
+				sessionID = "unknown"
+			}
+
+			session, err := env.auth.GetSession(context.Background(), sessionID)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			if session.ID != resp.SessionID || session.UserID != resp.User.ID || session.Email != "ayu@example.com" {
+				t.Errorf("session = %+v", session)
+			}
+		})
+	}
+}
+
+func TestAuthServiceValidateSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, env *testEnv, resp *service.AuthResponse)
+		wantErr error
+	}{
+		{name: "returns the session's user"},
+		{
+			name:    "rejects a deactivated user",
+			setup:   func(t *testing.T, env *testEnv, resp *service.AuthResponse) { env.deactivate(t, "ayu@example.com") },
+			wantErr: domain.ErrAccountDeactivated,
+		},
+		{
+			name: "rejects a session whose user was deleted",
+			setup: func(t *testing.T, env *testEnv, resp *service.AuthResponse) {
+				if err := env.users.Delete(context.Background(), resp.User.ID, resp.User.Version); err != nil {
+					t.Fatalf("Delete error = %v", err)
+				}
+			},
+			wantErr: domain.ErrSessionNotFound,
+		},
+		{
+			name: "rejects an expired session",
+			setup: func(t *testing.T, env *testEnv, resp *service.AuthResponse) {
+				env.clock.Advance(env.config.Session.ExpiresIn)
+			},
+			wantErr: domain.ErrSessionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			resp := env.register(t, "ayu@example.com")
+			if tt.setup != nil {
+				tt.setup(t, env, resp)
+			}
+
+			user, err := env.auth.ValidateSession(context.Background(), resp.SessionID)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			if user.ID.Hex() != resp.User.ID {
+				t.Errorf("user = %s, want %s", user.ID.Hex(), resp.User.ID)
+			}
+		})
+	}
+}
@@ -14,6 +14,49 @@ type AuthService interface {
 	ResetPassword(ctx context.Context, req ResetPasswordRequest) error
 	GetSession(ctx context.Context, sessionID string) (*domain.Session, error)
 	ValidateSession(ctx context.Context, sessionID string) (*domain.User, error)
+	VerifyLogin(ctx context.Context, req VerifyLoginRequest) (*AuthResponse, error)
+}
+
+// LoginRiskService defines the interface for suspicious login detection
+type LoginRiskService interface {
+	Assess(ctx context.Context, user *domain.User, ipAddress, userAgent string) (*domain.LoginEvent, error)
+	Record(ctx context.Context, user *domain.User, event *domain.LoginEvent) error
+}
+
+// OIDCService defines the interface for OpenID Connect login federation
+type OIDCService interface {
+	Providers() []string
+	// AuthorizationURL returns the provider's login URL and the state it
+	// carries, which the callback must come back with from the same browser
+	AuthorizationURL(ctx context.Context, provider string) (url, state string, err error)
+	Login(ctx context.Context, provider, code, state string) (*AuthResponse, error)
+}
+
+// APIKeyService defines the interface for API key management
+type APIKeyService interface {
+	Create(ctx context.Context, req CreateAPIKeyRequest, createdBy string) (*CreateAPIKeyResponse, error)
+	List(ctx context.Context) ([]*domain.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error)
+}
+
+// NetworkPolicyService defines the interface for per-role network access policies
+type NetworkPolicyService interface {
+	Authorize(ctx context.Context, role domain.UserRole, ipAddress, actorID, path string) error
+	List(ctx context.Context) ([]*domain.NetworkPolicy, error)
+	Update(ctx context.Context, role domain.UserRole, req UpdateNetworkPolicyRequest, updatedBy string) (*domain.NetworkPolicy, error)
+}
+
+// UserService defines the interface for user administration. Updates and
+// deletes take the version of the user they were made against and fail with
+// domain.ErrVersionMismatch once it is no longer current. Deleted users can
+// be restored until PurgeDeleted anonymises them after the retention period.
+type UserService interface {
+	Get(ctx context.Context, id string) (*UserResponse, error)
+	Update(ctx context.Context, id string, version int64, req UpdateUserRequest, updatedBy string) (*UserResponse, error)
+	Delete(ctx context.Context, id string, version int64, deletedBy string) error
+	Restore(ctx context.Context, id, restoredBy string) (*UserResponse, error)
+	PurgeDeleted(ctx context.Context) (int64, error)
 }
 
 // RegisterRequest represents a user registration request
@@ -27,8 +70,21 @@ type RegisterRequest struct {
 
 // LoginRequest represents a user login request
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email     string `json:"email" validate:"required,email"`
+	Password  string `json:"password" validate:"required"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// VerifyLoginRequest represents a step-up verification of a flagged login
+type VerifyLoginRequest struct {
+	ChallengeID string `json:"challenge_id" validate:"required"`
+	Code        string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// RequestPasswordResetRequest represents a request for a password reset email
+type RequestPasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 // ResetPasswordRequest represents a password reset request
@@ -37,12 +93,44 @@ type ResetPasswordRequest struct {
 	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
+// CreateAPIKeyRequest represents a request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name      string               `json:"name" validate:"required,min=3"`
+	Scopes    []domain.APIKeyScope `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *int64               `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse contains the issued key, which is only shown once
+type CreateAPIKeyResponse struct {
+	APIKey *domain.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+// UpdateNetworkPolicyRequest represents a request to replace a role's network policy
+type UpdateNetworkPolicyRequest struct {
+	Allow []string `json:"allow" validate:"dive,cidr"`
+	Deny  []string `json:"deny" validate:"dive,cidr"`
+}
+
+// UpdateUserRequest represents a partial update of a user; omitted fields
+// are left unchanged
+type UpdateUserRequest struct {
+	FirstName *string          `json:"first_name,omitempty" validate:"omitempty,min=2"`
+	LastName  *string          `json:"last_name,omitempty" validate:"omitempty,min=2"`
+	Role      *domain.UserRole `json:"role,omitempty"`
+	IsActive  *bool            `json:"is_active,omitempty"`
+}
+
 // AuthResponse represents an authentication response
 type AuthResponse struct {
-	User      *UserResponse `json:"user"`
-	Token     string        `json:"token"`
-	SessionID string        `json:"session_id"`
-	ExpiresAt int64         `json:"expires_at"`
+	User      *UserResponse `json:"user,omitempty"`
+	Token     string        `json:"token,omitempty"`
+	SessionID string        `json:"session_id,omitempty"`
+	ExpiresAt int64         `json:"expires_at,omitempty"`
+	// StepUpRequired is set instead of a session when a flagged login must
+	// be confirmed with the code emailed to the user
+	StepUpRequired bool   `json:"step_up_required,omitempty"`
+	ChallengeID    string `json:"challenge_id,omitempty"`
 }
 
 // UserResponse represents a user response (without sensitive data)
@@ -55,6 +143,7 @@ type UserResponse struct {
 	IsActive      bool            `json:"is_active"`
 	EmailVerified bool            `json:"email_verified"`
 	CreatedAt     int64           `json:"created_at"`
+	Version       int64           `json:"version"`
 }
 
 // ToUserResponse converts a domain.User to UserResponse
@@ -68,5 +157,6 @@ func ToUserResponse(user *domain.User) *UserResponse {
 		IsActive:      user.IsActive,
 		EmailVerified: user.EmailVerified,
 		CreatedAt:     user.CreatedAt.Unix(),
+		Version:       user.Version,
 	}
 }
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/mailer"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/pkg/utils"
+	"time"
+)
+
+const (
+	// minTravelDistanceKm ignores short hops that are within GeoIP accuracy
+	minTravelDistanceKm = 100
+	// minHistoryForHours is the history needed before login hours are judged
+	minHistoryForHours = 5
+)
+
+type loginRiskService struct {
+	loginEventRepo repository.LoginEventRepository
+	geoLocator     utils.GeoLocator
+	mailer         mailer.Mailer
+	location       *time.Location
+	config         config.LoginRiskConfig
+	clock          clock.Clock
+}
+
+// NewLoginRiskService creates a new suspicious login detection service
+func NewLoginRiskService(
+	loginEventRepo repository.LoginEventRepository,
+	geoLocator utils.GeoLocator,
+	mailer mailer.Mailer,
+	config *config.Config,
+	clock clock.Clock,
+) LoginRiskService {
+	location, err := time.LoadLocation(config.LoginRisk.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return &loginRiskService{
+		loginEventRepo: loginEventRepo,
+		geoLocator:     geoLocator,
+		mailer:         mailer,
+		location:       location,
+		config:         config.LoginRisk,
+		clock:          clock,
+	}
+}
+
+func (s *loginRiskService) Assess(ctx context.Context, user *domain.User, ipAddress, userAgent string) (*domain.LoginEvent, error) {
+	event := &domain.LoginEvent{
+		UserID:    user.ID.Hex(),
+		IPAddress: ipAddress,
+		IPRange:   utils.IPRange(ipAddress),
+		UserAgent: userAgent,
+		DeviceID:  utils.HashToken(userAgent)[:16],
+		Anomalies: []domain.LoginAnomaly{},
+		CreatedAt: s.clock.Now(),
+	}
+
+	if !s.config.Enabled {
+		return event, nil
+	}
+
+	if location, err := s.geoLocator.Locate(ipAddress); err == nil {
+		event.Country = location.Country
+		event.City = location.City
+		event.Latitude = &location.Latitude
+		event.Longitude = &location.Longitude
+	}
+
+	history, err := s.loginEventRepo.ListRecentByUser(ctx, event.UserID, s.config.HistorySize)
+	if err != nil {
+		return event, fmt.Errorf("failed to load login history: %w", err)
+	}
+
+	// There is nothing to compare a first login against
+	if len(history) == 0 {
+		return event, nil
+	}
+
+	if !s.seenBefore(history, func(e *domain.LoginEvent) bool { return e.DeviceID == event.DeviceID }) {
+		event.Anomalies = append(event.Anomalies, domain.AnomalyNewDevice)
+	}
+
+	if !s.seenBefore(history, func(e *domain.LoginEvent) bool { return e.IPRange == event.IPRange }) {
+		event.Anomalies = append(event.Anomalies, domain.AnomalyNewNetwork)
+	}
+
+	if s.isImpossibleTravel(history, event) {
+		event.Anomalies = append(event.Anomalies, domain.AnomalyImpossibleTravel)
+	}
+
+	if s.isUnusualHour(history, event) {
+		event.Anomalies = append(event.Anomalies, domain.AnomalyUnusualHours)
+	}
+
+	return event, nil
+}
+
+func (s *loginRiskService) Record(ctx context.Context, user *domain.User, event *domain.LoginEvent) error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	err := s.loginEventRepo.Create(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to record login event: %w", err)
+	}
+
+	if event.HasAnomaly(domain.AnomalyNewDevice) {
+		err = s.mailer.Send(ctx, user.Email, "New sign-in to your Future Star Center account", newDeviceEmail(user, event, s.location))
+		if err != nil {
+			return fmt.Errorf("failed to send new device notification: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *loginRiskService) seenBefore(history []*domain.LoginEvent, match func(*domain.LoginEvent) bool) bool {
+	for _, e := range history {
+		if match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// isImpossibleTravel checks if the distance from the last located login could
+// not have been covered in the time since
+func (s *loginRiskService) isImpossibleTravel(history []*domain.LoginEvent, event *domain.LoginEvent) bool {
+	if event.Latitude == nil || event.Longitude == nil {
+		return false
+	}
+
+	for _, previous := range history {
+		if previous.Latitude == nil || previous.Longitude == nil {
+			continue
+		}
+
+		distance := utils.DistanceKm(*previous.Latitude, *previous.Longitude, *event.Latitude, *event.Longitude)
+		if distance < minTravelDistanceKm {
+			return false
+		}
+
+		hours := event.CreatedAt.Sub(previous.CreatedAt).Hours()
+		if hours <= 0 {
+			return true
+		}
+		return distance/hours > s.config.MaxTravelSpeedKmh
+	}
+
+	return false
+}
+
+// isUnusualHour checks if the login is more than an hour away from the time
+// of day of every previous login
+func (s *loginRiskService) isUnusualHour(history []*domain.LoginEvent, event *domain.LoginEvent) bool {
+	if len(history) < minHistoryForHours {
+		return false
+	}
+
+	hour := event.CreatedAt.In(s.location).Hour()
+	for _, previous := range history {
+		diff := hour - previous.CreatedAt.In(s.location).Hour()
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > 12 {
+			diff = 24 - diff
+		}
+		if diff <= 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newDeviceEmail(user *domain.User, event *domain.LoginEvent, location *time.Location) string {
+	place := "an unknown location"
+	if event.City != "" || event.Country != "" {
+		place = fmt.Sprintf("%s %s", event.City, event.Country)
+	}
+
+	return fmt.Sprintf(`Hello %s,
+
+Your account was signed in to from a new device.
+
+Time:     %s
+Device:   %s
+Location: %s (IP %s)
+
+If this was you, no action is needed. If not, reset your password immediately
+and contact your administrator.
+`,
+		user.GetFullName(),
+		event.CreatedAt.In(location).Format("02 Jan 2006 15:04 MST"),
+		event.UserAgent,
+		place,
+		event.IPAddress,
+	)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// networkPolicyCacheTTL bounds how long a policy change on another replica
+// takes to be enforced here
+const networkPolicyCacheTTL = 30 * time.Second
+
+type networkPolicyService struct {
+	policyRepo repository.NetworkPolicyRepository
+	auditRepo  repository.AuditRepository
+	defaults   map[domain.UserRole]*domain.NetworkPolicy
+	logger     *slog.Logger
+	clock      clock.Clock
+
+	mu        sync.RWMutex
+	policies  map[domain.UserRole]*domain.NetworkPolicy
+	expiresAt time.Time
+}
+
+// NewNetworkPolicyService creates a new network policy service
+func NewNetworkPolicyService(
+	policyRepo repository.NetworkPolicyRepository,
+	auditRepo repository.AuditRepository,
+	config *config.Config,
+	logger *slog.Logger,
+	clock clock.Clock,
+) NetworkPolicyService {
+	defaults := make(map[domain.UserRole]*domain.NetworkPolicy)
+	for role, policy := range config.Network.Policies {
+		defaults[domain.UserRole(role)] = &domain.NetworkPolicy{
+			Role:  domain.UserRole(role),
+			Allow: policy.Allow,
+			Deny:  policy.Deny,
+		}
+	}
+
+	return &networkPolicyService{
+		policyRepo: policyRepo,
+		auditRepo:  auditRepo,
+		defaults:   defaults,
+		logger:     logger,
+		clock:      clock,
+	}
+}
+
+func (s *networkPolicyService) Authorize(ctx context.Context, role domain.UserRole, ipAddress, actorID, path string) error {
+	policy := s.policy(ctx, role)
+	if policy == nil || policy.Allows(ipAddress) {
+		return nil
+	}
+
+	err := s.auditRepo.Create(ctx, &domain.AuditEntry{
+		Action:    domain.AuditNetworkPolicyBlocked,
+		ActorID:   actorID,
+		ActorRole: role,
+		IPAddress: ipAddress,
+		Details: map[string]interface{}{
+			"path": path,
+		},
+	})
+	if err != nil {
+		// Log error but still block the request
+		s.logger.ErrorContext(ctx, "failed to audit blocked request", "user_id", actorID, "error", err)
+	}
+
+	s.logger.WarnContext(ctx, "request blocked by network policy", "user_id", actorID, "role", role, "ip", ipAddress, "path", path)
+	return domain.ErrNetworkNotAllowed
+}
+
+func (s *networkPolicyService) List(ctx context.Context) ([]*domain.NetworkPolicy, error) {
+	policies, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*domain.NetworkPolicy, 0, len(policies))
+	for _, role := range []domain.UserRole{domain.RoleAdmin, domain.RoleTherapist, domain.RoleStaff} {
+		if policy, ok := policies[role]; ok {
+			list = append(list, policy)
+		}
+	}
+	return list, nil
+}
+
+func (s *networkPolicyService) Update(
+	ctx context.Context,
+	role domain.UserRole,
+	req UpdateNetworkPolicyRequest,
+	updatedBy string,
+) (*domain.NetworkPolicy, error) {
+	if !role.IsValid() {
+		return nil, domain.ErrInvalidRole
+	}
+
+	for _, cidr := range append(append([]string{}, req.Allow...), req.Deny...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, domain.NewValidationError(fmt.Sprintf("invalid CIDR: %s", cidr))
+		}
+	}
+
+	policy := &domain.NetworkPolicy{
+		Role:      role,
+		Allow:     nonNil(req.Allow),
+		Deny:      nonNil(req.Deny),
+		UpdatedBy: updatedBy,
+	}
+
+	err := s.policyRepo.Upsert(ctx, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save network policy: %w", err)
+	}
+
+	err = s.auditRepo.Create(ctx, &domain.AuditEntry{
+		Action:  domain.AuditNetworkPolicyUpdated,
+		ActorID: updatedBy,
+		Details: map[string]interface{}{
+			"role":  role,
+			"allow": policy.Allow,
+			"deny":  policy.Deny,
+		},
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to audit network policy update", "user_id", updatedBy, "error", err)
+	}
+
+	// Force a reload on the next request
+	s.mu.Lock()
+	s.expiresAt = time.Time{}
+	s.mu.Unlock()
+
+	return policy, nil
+}
+
+// policy returns the effective policy for a role, falling back to the last
+// known policies if the database cannot be reached
+func (s *networkPolicyService) policy(ctx context.Context, role domain.UserRole) *domain.NetworkPolicy {
+	policies, err := s.load(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to load network policies", "error", err)
+		s.mu.RLock()
+		policies = s.policies
+		s.mu.RUnlock()
+		if policies == nil {
+			policies = s.defaults
+		}
+	}
+	return policies[role]
+}
+
+// load returns the cached policies, refreshing them once the cache expires
+func (s *networkPolicyService) load(ctx context.Context) (map[domain.UserRole]*domain.NetworkPolicy, error) {
+	s.mu.RLock()
+	if s.clock.Now().Before(s.expiresAt) {
+		policies := s.policies
+		s.mu.RUnlock()
+		return policies, nil
+	}
+	s.mu.RUnlock()
+
+	stored, err := s.policyRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[domain.UserRole]*domain.NetworkPolicy, len(s.defaults)+len(stored))
+	for role, policy := range s.defaults {
+		policies[role] = policy
+	}
+	for _, policy := range stored {
+		policies[policy.Role] = policy
+	}
+
+	s.mu.Lock()
+	s.policies = policies
+	s.expiresAt = s.clock.Now().Add(networkPolicyCacheTTL)
+	s.mu.Unlock()
+
+	return policies, nil
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
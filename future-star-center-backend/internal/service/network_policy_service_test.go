@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeNetworkPolicyRepo stores policies in memory and fails while err is set
+type fakeNetworkPolicyRepo struct {
+	policies map[domain.UserRole]*domain.NetworkPolicy
+	err      error
+}
+
+func (f *fakeNetworkPolicyRepo) List(ctx context.Context) ([]*domain.NetworkPolicy, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	policies := []*domain.NetworkPolicy{}
+	for _, policy := range f.policies {
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (f *fakeNetworkPolicyRepo) Upsert(ctx context.Context, policy *domain.NetworkPolicy) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.policies[policy.Role] = policy
+	return nil
+}
+
+// networkPolicyTestEnv is a NetworkPolicyService whose configuration only
+// lets admins in from 10.0.0.0/8
+type networkPolicyTestEnv struct {
+	policies service.NetworkPolicyService
+	repo     *fakeNetworkPolicyRepo
+	audit    *fakeAudit
+	clock    *clock.Fake
+}
+
+func newNetworkPolicyTestEnv(t *testing.T) *networkPolicyTestEnv {
+	t.Helper()
+
+	env := &networkPolicyTestEnv{
+		repo:  &fakeNetworkPolicyRepo{policies: make(map[domain.UserRole]*domain.NetworkPolicy)},
+		audit: &fakeAudit{},
+		clock: clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)),
+	}
+	cfg := config.Default()
+	cfg.Network.Policies = map[string]config.NetworkPolicyConfig{
+		string(domain.RoleAdmin): {Allow: []string{"10.0.0.0/8"}},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env.policies = service.NewNetworkPolicyService(env.repo, env.audit, cfg, logger, env.clock)
+	return env
+}
+
+func TestNetworkPolicyServiceAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored []*domain.NetworkPolicy
+		role   domain.UserRole
+		ip     string
+		want   error
+	}{
+		{
+			name: "applies the configured policy",
+			role: domain.RoleAdmin,
+			ip:   "10.1.2.3",
+		},
+		{
+			name: "blocks an address outside the configured policy",
+			role: domain.RoleAdmin,
+			ip:   "203.0.113.7",
+			want: domain.ErrNetworkNotAllowed,
+		},
+		{
+			name: "allows a role without a policy",
+			role: domain.RoleTherapist,
+			ip:   "203.0.113.7",
+		},
+		{
+			name:   "replaces the configured policy with the stored one",
+			stored: []*domain.NetworkPolicy{{Role: domain.RoleAdmin, Allow: []string{"203.0.113.0/24"}}},
+			role:   domain.RoleAdmin,
+			ip:     "203.0.113.7",
+		},
+		{
+			name: "lets deny take precedence over allow",
+			stored: []*domain.NetworkPolicy{{
+				Role:  domain.RoleStaff,
+				Allow: []string{"203.0.113.0/24"},
+				Deny:  []string{"203.0.113.7/32"},
+			}},
+			role: domain.RoleStaff,
+			ip:   "203.0.113.7",
+			want: domain.ErrNetworkNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newNetworkPolicyTestEnv(t)
+			for _, policy := range tt.stored {
+				env.repo.policies[policy.Role] = policy
+			}
+
+			err := env.policies.Authorize(context.Background(), tt.role, tt.ip, "actor-id", "/api/v1/admin/users")
+			assertError(t, err, tt.want)
+
+			// Only blocked requests are audited
+			if tt.want == nil {
+				if len(env.audit.entries) != 0 {
+					t.Errorf("audited %d entries, want none", len(env.audit.entries))
+				}
+				return
+			}
+			if len(env.audit.entries) != 1 {
+				t.Fatalf("audited %d entries, want 1", len(env.audit.entries))
+			}
+			entry := env.audit.entries[0]
+			if entry.Action != domain.AuditNetworkPolicyBlocked || entry.ActorID != "actor-id" ||
+				entry.ActorRole != tt.role || entry.IPAddress != tt.ip || entry.Details["path"] != "/api/v1/admin/users" {
+				t.Errorf("audit entry = %+v, want the blocked request", entry)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyServiceDatabaseDown(t *testing.T) {
+	ctx := context.Background()
+	officeOnly := &domain.NetworkPolicy{Role: domain.RoleAdmin, Allow: []string{"203.0.113.0/24"}}
+
+	t.Run("keeps enforcing the last known policies", func(t *testing.T) {
+		env := newNetworkPolicyTestEnv(t)
+		env.repo.policies[domain.RoleAdmin] = officeOnly
+		assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "203.0.113.7", "actor-id", "/"), nil)
+
+		env.repo.err = errors.New("mongo is down")
+		env.clock.Advance(time.Hour)
+		assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "203.0.113.7", "actor-id", "/"), nil)
+		assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "10.1.2.3", "actor-id", "/"), domain.ErrNetworkNotAllowed)
+	})
+
+	t.Run("falls back to the configured policies", func(t *testing.T) {
+		env := newNetworkPolicyTestEnv(t)
+		env.repo.policies[domain.RoleAdmin] = officeOnly
+		env.repo.err = errors.New("mongo is down")
+
+		assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "10.1.2.3", "actor-id", "/"), nil)
+		assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "203.0.113.7", "actor-id", "/"), domain.ErrNetworkNotAllowed)
+	})
+}
+
+func TestNetworkPolicyServiceUpdate(t *testing.T) {
+	ctx := context.Background()
+	env := newNetworkPolicyTestEnv(t)
+	assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "203.0.113.7", "actor-id", "/"), domain.ErrNetworkNotAllowed)
+
+	_, err := env.policies.Update(ctx, domain.RoleAdmin, service.UpdateNetworkPolicyRequest{Allow: []string{"203.0.113.0/24"}}, "admin-id")
+	assertError(t, err, nil)
+
+	// The change applies at once on this replica, without waiting for the cache
+	assertError(t, env.policies.Authorize(ctx, domain.RoleAdmin, "203.0.113.7", "actor-id", "/"), nil)
+
+	_, err = env.policies.Update(ctx, domain.RoleAdmin, service.UpdateNetworkPolicyRequest{Allow: []string{"203.0.113.0/33"}}, "admin-id")
+	assertError(t, err, domain.ErrValidation)
+	_, err = env.policies.Update(ctx, domain.UserRole("owner"), service.UpdateNetworkPolicyRequest{}, "admin-id")
+	assertError(t, err, domain.ErrInvalidRole)
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/metrics"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/tracing"
+	"future-star-center-backend/pkg/utils"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+type oidcService struct {
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	stateRepo   repository.OIDCStateRepository
+	providers   map[string]*oidcProvider
+	config      *config.Config
+	logger      *slog.Logger
+	metrics     *metrics.Metrics
+	clock       clock.Clock
+}
+
+// oidcProvider lazily discovers a provider's endpoints so that an unreachable
+// identity provider does not prevent the server from starting
+type oidcProvider struct {
+	config   config.OIDCProviderConfig
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcClaims holds the ID token claims used to identify a user
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+// NewOIDCService creates a new OpenID Connect federation service
+func NewOIDCService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	stateRepo repository.OIDCStateRepository,
+	config *config.Config,
+	logger *slog.Logger,
+	metrics *metrics.Metrics,
+	clock clock.Clock,
+) OIDCService {
+	providers := make(map[string]*oidcProvider)
+	for _, providerConfig := range config.OIDC.Providers {
+		providers[providerConfig.Name] = &oidcProvider{config: providerConfig}
+	}
+
+	return &oidcService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		stateRepo:   stateRepo,
+		providers:   providers,
+		config:      config,
+		logger:      logger,
+		metrics:     metrics,
+		clock:       clock,
+	}
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *oidcService) AuthorizationURL(ctx context.Context, providerName string) (string, string, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	authState := &domain.OIDCAuthState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Provider:     providerName,
+		CreatedAt:    s.clock.Now(),
+	}
+
+	err = s.stateRepo.Save(ctx, authState, s.config.OIDC.StateExpiresIn)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	url := provider.oauth2.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(authState.CodeVerifier),
+	)
+	return url, state, nil
+}
+
+func (s *oidcService) Login(ctx context.Context, providerName, code, state string) (resp *AuthResponse, err error) {
+	ctx, span := tracer.Start(ctx, "OIDCService.Login", trace.WithAttributes(attribute.String("oidc.provider", providerName)))
+	defer func() { tracing.EndSpan(span, err) }()
+
+	resp, err = s.login(ctx, providerName, code, state)
+	if err != nil {
+		s.metrics.RecordLogin("oidc", metrics.LoginFailed)
+	} else {
+		s.metrics.RecordLogin("oidc", metrics.LoginSucceeded)
+	}
+	return resp, err
+}
+
+func (s *oidcService) login(ctx context.Context, providerName, code, state string) (*AuthResponse, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Consume the state first so it cannot be replayed even if the login fails
+	authState, err := s.stateRepo.Consume(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if authState.Provider != providerName {
+		return nil, domain.ErrInvalidLoginState
+	}
+
+	// Exchange the authorization code using the PKCE verifier
+	token, err := provider.oauth2.Exchange(ctx, code, oauth2.VerifierOption(authState.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w: %w", domain.ErrInvalidAuthCode, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, domain.ErrInvalidIDToken
+	}
+
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ID token: %w: %w", domain.ErrInvalidIDToken, err)
+	}
+
+	if idToken.Nonce != authState.Nonce {
+		return nil, domain.ErrInvalidIDToken
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse ID token claims: %w: %w", domain.ErrInvalidIDToken, err)
+	}
+
+	if claims.Email == "" || (!claims.EmailVerified && !provider.config.TrustEmail) {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	user, err := s.resolveUser(ctx, provider.config, idToken.Subject, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if user is active
+	if !user.IsActive {
+		return nil, domain.ErrAccountDeactivated
+	}
+
+	// Update last login
+	err = s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
+	if err != nil {
+		// Log error but don't fail the login
+		s.logger.ErrorContext(ctx, "failed to update last login", "user_id", user.ID.Hex(), "error", err)
+	}
+
+	return newAuthResponse(ctx, s.sessionRepo, s.config, s.clock.Now(), user)
+}
+
+// resolveUser finds the user linked to an external identity, linking an
+// existing account by email or provisioning a new one when allowed
+func (s *oidcService) resolveUser(
+	ctx context.Context,
+	providerConfig config.OIDCProviderConfig,
+	subject string,
+	claims oidcClaims,
+) (*domain.User, error) {
+	// Already linked
+	user, err := s.userRepo.GetByExternalIdentity(ctx, providerConfig.Name, subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+
+	email := strings.ToLower(claims.Email)
+	identity := domain.ExternalIdentity{
+		Provider: providerConfig.Name,
+		Subject:  subject,
+		Email:    email,
+		LinkedAt: s.clock.Now(),
+	}
+
+	// Link an existing account with the same email
+	user, err = s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	_, emailDomain, _ := strings.Cut(email, "@")
+	if err == nil {
+		if !canLinkAccount(providerConfig, user, claims, emailDomain) {
+			s.logger.WarnContext(ctx, "refused to link external identity to existing account",
+				"provider", providerConfig.Name, "user_id", user.ID.Hex(), "email_verified", claims.EmailVerified)
+			return nil, domain.ErrIdentityLinkRefused
+		}
+
+		err = s.userRepo.LinkExternalIdentity(ctx, user.ID.Hex(), identity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link external identity: %w", err)
+		}
+		return user, nil
+	}
+
+	// Provision a new account if the email domain allows it
+	role := domain.UserRole(providerConfig.JITRoles[emailDomain])
+	if !role.IsValid() {
+		return nil, domain.ErrNoAccountForIdentity
+	}
+
+	// Federated users sign in through their provider, so the password is
+	// random and unknown to anyone until a password reset is requested
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	hashedPassword, err := hashPassword(ctx, randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user = &domain.User{
+		Email:     email,
+		Password:  hashedPassword,
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+		Role:      role,
+	}
+
+	err = s.userRepo.Create(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	err = s.userRepo.LinkExternalIdentity(ctx, user.ID.Hex(), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link external identity: %w", err)
+	}
+	user.EmailVerified = true
+
+	return user, nil
+}
+
+// canLinkAccount reports whether an identity may be linked to the existing
+// account sharing its email. Anyone able to register that email with the
+// provider could otherwise sign in as the account's owner, so the email must
+// be verified by the provider, or trusted and within a domain the provider
+// is configured to provision. Admins are only linked when explicitly allowed.
+func canLinkAccount(providerConfig config.OIDCProviderConfig, user *domain.User, claims oidcClaims, emailDomain string) bool {
+	if user.Role == domain.RoleAdmin && !providerConfig.LinkAdmins {
+		return false
+	}
+	if claims.EmailVerified {
+		return true
+	}
+	_, listed := providerConfig.JITRoles[emailDomain]
+	return providerConfig.TrustEmail && listed
+}
+
+// provider returns the named provider, running discovery on first use
+func (s *oidcService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, domain.ErrUnknownProvider
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if provider.oauth2 != nil {
+		return provider, nil
+	}
+
+	discovered, err := oidc.NewProvider(ctx, provider.config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider: %w", err)
+	}
+
+	provider.oauth2 = &oauth2.Config{
+		ClientID:     provider.config.ClientID,
+		ClientSecret: provider.config.ClientSecret,
+		RedirectURL:  provider.config.RedirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       provider.config.Scopes,
+	}
+	provider.verifier = discovered.Verifier(&oidc.Config{ClientID: provider.config.ClientID})
+
+	return provider, nil
+}
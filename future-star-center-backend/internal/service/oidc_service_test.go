@@ -0,0 +1,450 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID     = "future-star"
+	mockClientSecret = "mock-client-secret"
+	mockRedirectURL  = "https://api.example.com/api/v1/auth/oidc/mock/callback"
+)
+
+// mockProvider is an OpenID Connect provider serving discovery, its signing
+// key and a token endpoint that checks the PKCE verifier of each code
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+// mockGrant is what an authorization code is exchanged for
+type mockGrant struct {
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key, grants: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "mock",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != mockClientID || clientSecret != mockClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, ok := p.grants[r.PostFormValue("code")]
+	delete(p.grants, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": p.server.URL,
+		"aud": mockClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for name, value := range grant.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// issue registers an authorization code for the given PKCE challenge
+func (p *mockProvider) issue(code, codeChallenge string, claims jwt.MapClaims) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.grants[code] = mockGrant{codeChallenge: codeChallenge, claims: claims}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// oidcTestEnv is an OIDCService for the mock provider backed by in-memory
+// repositories
+type oidcTestEnv struct {
+	oidc     service.OIDCService
+	provider *mockProvider
+	users    repository.UserRepository
+	clock    *clock.Fake
+}
+
+func newOIDCTestEnv(t *testing.T, configure func(provider *config.OIDCProviderConfig)) *oidcTestEnv {
+	t.Helper()
+
+	env := &oidcTestEnv{
+		provider: newMockProvider(t),
+		clock:    clock.NewFake(time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)),
+	}
+	env.users = repository.NewMemoryUserRepository(env.clock)
+
+	cfg := config.Default()
+	providerConfig := config.OIDCProviderConfig{
+		Name:         "mock",
+		IssuerURL:    env.provider.server.URL,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		RedirectURL:  mockRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		JITRoles:     map[string]string{"clinic.org": string(domain.RoleStaff)},
+	}
+	if configure != nil {
+		configure(&providerConfig)
+	}
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{providerConfig}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	env.oidc = service.NewOIDCService(
+		env.users,
+		repository.NewMemorySessionRepository(env.clock),
+		repository.NewMemoryOIDCStateRepository(env.clock),
+		cfg,
+		logger,
+		nil,
+		env.clock,
+	)
+	return env
+}
+
+// authorization is what the browser carries to the provider
+type authorization struct {
+	state         string
+	nonce         string
+	codeChallenge string
+}
+
+// authorize starts a login and returns the parameters of its redirect
+func (env *oidcTestEnv) authorize(t *testing.T) authorization {
+	t.Helper()
+
+	rawURL, state, err := env.oidc.AuthorizationURL(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL() error = %v", err)
+	}
+	redirect, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("AuthorizationURL() = %q: %v", rawURL, err)
+	}
+
+	query := redirect.Query()
+	if query.Get("state") != state {
+		t.Fatalf("state in URL = %q, want %q", query.Get("state"), state)
+	}
+	return authorization{
+		state:         state,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+}
+
+// mustCreateUser adds an existing local account
+func (env *oidcTestEnv) mustCreateUser(t *testing.T, email string, role domain.UserRole) *domain.User {
+	t.Helper()
+
+	user := &domain.User{Email: email, Password: "hash", FirstName: "Ayu", LastName: "Lestari", Role: role}
+	if err := env.users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return user
+}
+
+func TestOIDCServiceAuthorizationURL(t *testing.T) {
+	env := newOIDCTestEnv(t, nil)
+
+	rawURL, state, err := env.oidc.AuthorizationURL(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("AuthorizationURL() error = %v", err)
+	}
+	if state == "" {
+		t.Error("AuthorizationURL() returned an empty state")
+	}
+
+	// The endpoint and parameters come from the provider's discovery document
+	if !strings.HasPrefix(rawURL, env.provider.server.URL+"/authorize?") {
+		t.Errorf("AuthorizationURL() = %q, want the provider's authorization endpoint", rawURL)
+	}
+	redirect, _ := url.Parse(rawURL)
+	query := redirect.Query()
+	want := map[string]string{
+		"client_id":             mockClientID,
+		"redirect_uri":          mockRedirectURL,
+		"response_type":         "code",
+		"scope":                 "openid email profile",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if query.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, query.Get(name), value)
+		}
+	}
+	for _, name := range []string{"nonce", "code_challenge"} {
+		if query.Get(name) == "" {
+			t.Errorf("%s is missing from %q", name, rawURL)
+		}
+	}
+
+	_, _, err = env.oidc.AuthorizationURL(context.Background(), "unknown")
+	assertError(t, err, domain.ErrUnknownProvider)
+}
+
+func TestOIDCServiceAuthorizationURLUnreachableProvider(t *testing.T) {
+	env := newOIDCTestEnv(t, func(provider *config.OIDCProviderConfig) {
+		provider.IssuerURL = "http://127.0.0.1:1"
+	})
+
+	if _, _, err := env.oidc.AuthorizationURL(context.Background(), "mock"); err == nil {
+		t.Fatal("AuthorizationURL() error = nil, want a discovery error")
+	}
+}
+
+func TestOIDCServiceLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(provider *config.OIDCProviderConfig)
+		// existing is a local account created before the login
+		existing *domain.User
+		email    string
+		verified bool
+		// tamper changes the grant before the provider issues it
+		tamper   func(auth *authorization)
+		wantErr  error
+		wantRole domain.UserRole
+		// wantExisting expects the login to sign in to the existing account
+		wantExisting bool
+	}{
+		{
+			name:     "provisions a user of a listed domain",
+			email:    "new@clinic.org",
+			verified: true,
+			wantRole: domain.RoleStaff,
+		},
+		{
+			name:     "refuses a user of an unlisted domain",
+			email:    "new@example.com",
+			verified: true,
+			wantErr:  domain.ErrNoAccountForIdentity,
+		},
+		{
+			name:         "links an existing account with a verified email",
+			existing:     &domain.User{Email: "ayu@example.com", Role: domain.RoleTherapist},
+			email:        "Ayu@Example.com",
+			verified:     true,
+			wantRole:     domain.RoleTherapist,
+			wantExisting: true,
+		},
+		{
+			name:     "refuses an unverified email",
+			existing: &domain.User{Email: "ayu@example.com", Role: domain.RoleTherapist},
+			email:    "ayu@example.com",
+			wantErr:  domain.ErrEmailNotVerified,
+		},
+		{
+			name:      "refuses to link a trusted email outside the provisioned domains",
+			configure: func(provider *config.OIDCProviderConfig) { provider.TrustEmail = true },
+			existing:  &domain.User{Email: "ayu@example.com", Role: domain.RoleTherapist},
+			email:     "ayu@example.com",
+			wantErr:   domain.ErrIdentityLinkRefused,
+		},
+		{
+			name:         "links a trusted email within a provisioned domain",
+			configure:    func(provider *config.OIDCProviderConfig) { provider.TrustEmail = true },
+			existing:     &domain.User{Email: "ayu@clinic.org", Role: domain.RoleStaff},
+			email:        "ayu@clinic.org",
+			wantRole:     domain.RoleStaff,
+			wantExisting: true,
+		},
+		{
+			name:     "refuses to link an admin",
+			existing: &domain.User{Email: "admin@clinic.org", Role: domain.RoleAdmin},
+			email:    "admin@clinic.org",
+			verified: true,
+			wantErr:  domain.ErrIdentityLinkRefused,
+		},
+		{
+			name:         "links an admin when the provider allows it",
+			configure:    func(provider *config.OIDCProviderConfig) { provider.LinkAdmins = true },
+			existing:     &domain.User{Email: "admin@clinic.org", Role: domain.RoleAdmin},
+			email:        "admin@clinic.org",
+			verified:     true,
+			wantRole:     domain.RoleAdmin,
+			wantExisting: true,
+		},
+		{
+			name:     "rejects an ID token for another login",
+			email:    "new@clinic.org",
+			verified: true,
+			tamper:   func(auth *authorization) { auth.nonce = "another-login" },
+			wantErr:  domain.ErrInvalidIDToken,
+		},
+		{
+			name:     "fails the exchange without the PKCE verifier",
+			email:    "new@clinic.org",
+			verified: true,
+			tamper:   func(auth *authorization) { auth.codeChallenge = "another-challenge" },
+			wantErr:  domain.ErrInvalidAuthCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newOIDCTestEnv(t, tt.configure)
+			var existing *domain.User
+			if tt.existing != nil {
+				existing = env.mustCreateUser(t, tt.existing.Email, tt.existing.Role)
+			}
+
+			auth := env.authorize(t)
+			if tt.tamper != nil {
+				tt.tamper(&auth)
+			}
+			env.provider.issue("code", auth.codeChallenge, jwt.MapClaims{
+				"sub":            "mock-subject",
+				"nonce":          auth.nonce,
+				"email":          tt.email,
+				"email_verified": tt.verified,
+				"given_name":     "Ayu",
+				"family_name":    "Lestari",
+			})
+
+			resp, err := env.oidc.Login(ctx, "mock", "code", auth.state)
+			assertError(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			if resp.SessionID == "" {
+				t.Error("Login() returned no session")
+			}
+			if resp.User.Role != tt.wantRole {
+				t.Errorf("Role = %q, want %q", resp.User.Role, tt.wantRole)
+			}
+			if tt.wantExisting && resp.User.ID != existing.ID.Hex() {
+				t.Errorf("signed in to %s, want the existing account %s", resp.User.ID, existing.ID.Hex())
+			}
+
+			// The identity is linked, so the next login finds the user by it
+			linked, err := env.users.GetByExternalIdentity(ctx, "mock", "mock-subject")
+			if err != nil {
+				t.Fatalf("GetByExternalIdentity() error = %v", err)
+			}
+			if linked.ID.Hex() != resp.User.ID {
+				t.Errorf("identity linked to %s, want %s", linked.ID.Hex(), resp.User.ID)
+			}
+		})
+	}
+}
+
+func TestOIDCServiceLoginReplayedState(t *testing.T) {
+	ctx := context.Background()
+	env := newOIDCTestEnv(t, nil)
+
+	auth := env.authorize(t)
+	claims := jwt.MapClaims{"sub": "mock-subject", "nonce": auth.nonce, "email": "new@clinic.org", "email_verified": true}
+	env.provider.issue("first-code", auth.codeChallenge, claims)
+	if _, err := env.oidc.Login(ctx, "mock", "first-code", auth.state); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	env.provider.issue("second-code", auth.codeChallenge, claims)
+	_, err := env.oidc.Login(ctx, "mock", "second-code", auth.state)
+	assertError(t, err, domain.ErrInvalidLoginState)
+}
+
+func TestOIDCServiceLoginExpiredState(t *testing.T) {
+	ctx := context.Background()
+	env := newOIDCTestEnv(t, nil)
+
+	auth := env.authorize(t)
+	env.provider.issue("code", auth.codeChallenge, jwt.MapClaims{"sub": "mock-subject", "nonce": auth.nonce, "email": "new@clinic.org", "email_verified": true})
+	env.clock.Advance(config.Default().OIDC.StateExpiresIn)
+
+	_, err := env.oidc.Login(ctx, "mock", "code", auth.state)
+	assertError(t, err, domain.ErrInvalidLoginState)
+}
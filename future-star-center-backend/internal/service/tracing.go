@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"future-star-center-backend/pkg/utils"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("future-star-center-backend/internal/service")
+
+// hashPassword hashes a password in its own span, as bcrypt is deliberately slow
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "bcrypt.HashPassword")
+	defer span.End()
+	return utils.HashPassword(password)
+}
+
+// checkPassword checks a password in its own span, as bcrypt is deliberately slow
+func checkPassword(ctx context.Context, password, hash string) bool {
+	_, span := tracer.Start(ctx, "bcrypt.CheckPassword")
+	defer span.End()
+	return utils.CheckPassword(password, hash)
+}
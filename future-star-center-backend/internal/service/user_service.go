@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/clock"
+	"future-star-center-backend/internal/config"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/repository"
+	"log/slog"
+	"time"
+)
+
+type userService struct {
+	userRepo       repository.UserRepository
+	sessionRepo    repository.SessionRepository
+	loginEventRepo repository.LoginEventRepository
+	auditRepo      repository.AuditRepository
+	retention      time.Duration
+	logger         *slog.Logger
+	clock          clock.Clock
+}
+
+// NewUserService creates a new user administration service
+func NewUserService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	loginEventRepo repository.LoginEventRepository,
+	auditRepo repository.AuditRepository,
+	config *config.Config,
+	logger *slog.Logger,
+	clock clock.Clock,
+) UserService {
+	return &userService{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		loginEventRepo: loginEventRepo,
+		auditRepo:      auditRepo,
+		retention:      config.UserRetention.Period,
+		logger:         logger,
+		clock:          clock,
+	}
+}
+
+// Get reads the user past the cache, so that the version clients send back in
+// If-Match is current
+func (s *userService) Get(ctx context.Context, id string) (*UserResponse, error) {
+	user, err := s.userRepo.GetCurrentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ToUserResponse(user), nil
+}
+
+func (s *userService) Update(
+	ctx context.Context,
+	id string,
+	version int64,
+	req UpdateUserRequest,
+	updatedBy string,
+) (*UserResponse, error) {
+	// A cached copy may be older than the version the client read
+	user, err := s.userRepo.GetCurrentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Version != version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	changes := make(map[string]interface{})
+	if req.FirstName != nil && *req.FirstName != user.FirstName {
+		user.FirstName = *req.FirstName
+		changes["first_name"] = user.FirstName
+	}
+	if req.LastName != nil && *req.LastName != user.LastName {
+		user.LastName = *req.LastName
+		changes["last_name"] = user.LastName
+	}
+	if req.Role != nil && *req.Role != user.Role {
+		if !req.Role.IsValid() {
+			return nil, domain.ErrInvalidRole
+		}
+		user.Role = *req.Role
+		changes["role"] = user.Role
+	}
+	if req.IsActive != nil && *req.IsActive != user.IsActive {
+		user.IsActive = *req.IsActive
+		changes["is_active"] = user.IsActive
+	}
+
+	// Nothing to write, so the version stays the same
+	if len(changes) == 0 {
+		return ToUserResponse(user), nil
+	}
+
+	// The update only applies to the version the client read
+	err = s.userRepo.Update(ctx, user)
+	if errors.Is(err, domain.ErrVersionConflict) {
+		return nil, domain.ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	// Other replicas may keep serving the user's old role or active flag from
+	// their cache, so the user's sessions end and they must log in again
+	_, roleChanged := changes["role"]
+	_, activeChanged := changes["is_active"]
+	if roleChanged || activeChanged {
+		if err := s.sessionRepo.DeleteAllUserSessions(ctx, id); err != nil {
+			s.logger.ErrorContext(ctx, "failed to end sessions of updated user", "user_id", id, "error", err)
+		}
+	}
+
+	s.audit(ctx, &domain.AuditEntry{
+		Action:  domain.AuditUserUpdated,
+		ActorID: updatedBy,
+		Details: map[string]interface{}{
+			"user_id": id,
+			"version": user.Version,
+			"changes": changes,
+		},
+	})
+
+	return ToUserResponse(user), nil
+}
+
+func (s *userService) Delete(ctx context.Context, id string, version int64, deletedBy string) error {
+	err := s.userRepo.Delete(ctx, id, version)
+	if errors.Is(err, domain.ErrVersionConflict) {
+		return domain.ErrVersionMismatch
+	}
+	if err != nil {
+		return err
+	}
+
+	// Deleted users fail session validation anyway, but a cached copy of the
+	// user could keep their sessions working until it expires
+	if err := s.sessionRepo.DeleteAllUserSessions(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "failed to end sessions of deleted user", "user_id", id, "error", err)
+	}
+
+	s.audit(ctx, &domain.AuditEntry{
+		Action:  domain.AuditUserDeleted,
+		ActorID: deletedBy,
+		Details: map[string]interface{}{
+			"user_id": id,
+		},
+	})
+	return nil
+}
+
+func (s *userService) Restore(ctx context.Context, id, restoredBy string) (*UserResponse, error) {
+	if err := s.userRepo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetCurrentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, &domain.AuditEntry{
+		Action:  domain.AuditUserRestored,
+		ActorID: restoredBy,
+		Details: map[string]interface{}{
+			"user_id": id,
+		},
+	})
+	return ToUserResponse(user), nil
+}
+
+// PurgeDeleted deletes the login history of the users due for purging, with
+// their IP addresses, locations and user agents, and then anonymises the
+// users. The history goes first so that a failure leaves the users to be
+// purged by the next run.
+func (s *userService) PurgeDeleted(ctx context.Context) (int64, error) {
+	cutoff := s.clock.Now().Add(-s.retention)
+	ids, err := s.userRepo.ListDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list deleted users: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	events, err := s.loginEventRepo.DeleteByUsers(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login history of deleted users: %w", err)
+	}
+
+	purged, err := s.userRepo.PurgeDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	s.audit(ctx, &domain.AuditEntry{
+		Action: domain.AuditUsersPurged,
+		Details: map[string]interface{}{
+			"count":                purged,
+			"deleted_before":       cutoff,
+			"login_events_deleted": events,
+		},
+	})
+	return purged, nil
+}
+
+// audit records an audit entry. A failure is logged without failing the
+// change, which has already been made.
+func (s *userService) audit(ctx context.Context, entry *domain.AuditEntry) {
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		s.logger.ErrorContext(ctx, "failed to audit user change", "action", entry.Action, "user_id", entry.ActorID, "error", err)
+	}
+}
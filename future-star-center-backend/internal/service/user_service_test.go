@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/service"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeLoginEvents keeps login events in memory
+type fakeLoginEvents struct {
+	events []*domain.LoginEvent
+	err    error
+}
+
+func (f *fakeLoginEvents) Create(ctx context.Context, event *domain.LoginEvent) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeLoginEvents) ListRecentByUser(ctx context.Context, userID string, limit int) ([]*domain.LoginEvent, error) {
+	events := []*domain.LoginEvent{}
+	for _, event := range f.events {
+		if event.UserID == userID && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (f *fakeLoginEvents) DeleteByUsers(ctx context.Context, userIDs []string) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	kept := f.events[:0]
+	for _, event := range f.events {
+		if !containsString(userIDs, event.UserID) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := int64(len(f.events) - len(kept))
+	f.events = kept
+	return deleted, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeAudit records audit entries
+type fakeAudit struct {
+	entries []*domain.AuditEntry
+}
+
+func (f *fakeAudit) Create(ctx context.Context, entry *domain.AuditEntry) error {
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func TestUserServicePurgeDeleted(t *testing.T) {
+	tests := []struct {
+		name string
+		// deleteErr fails deleting the login history on the first run
+		deleteErr error
+	}{
+		{
+			name: "purges the user and their login history",
+		},
+		{
+			name:      "leaves the user to the next run when the login history cannot be deleted",
+			deleteErr: errors.New("mongo is down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(t)
+			loginEvents := &fakeLoginEvents{}
+			audit := &fakeAudit{}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			users := service.NewUserService(env.users, env.sessions, loginEvents, audit, env.config, logger, env.clock)
+
+			deleted := env.register(t, "ayu@example.com").User
+			kept := env.register(t, "budi@example.com").User
+			for _, user := range []*service.UserResponse{deleted, kept} {
+				loginEvents.Create(ctx, &domain.LoginEvent{UserID: user.ID, IPAddress: "203.0.113.7"})
+			}
+			if err := users.Delete(ctx, deleted.ID, deleted.Version, "admin"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			purged, err := users.PurgeDeleted(ctx)
+			if err != nil || purged != 0 {
+				t.Fatalf("PurgeDeleted() within the retention period = %d, %v, want 0, nil", purged, err)
+			}
+
+			env.clock.Advance(env.config.UserRetention.Period + time.Hour)
+			if tt.deleteErr != nil {
+				loginEvents.err = tt.deleteErr
+				_, err := users.PurgeDeleted(ctx)
+				assertError(t, err, tt.deleteErr)
+				if ids, _ := env.users.ListDeletedBefore(ctx, env.clock.Now()); len(ids) != 1 {
+					t.Fatalf("users due for purging after the failure = %v, want the deleted user", ids)
+				}
+				loginEvents.err = nil
+			}
+
+			purged, err = users.PurgeDeleted(ctx)
+			if err != nil || purged != 1 {
+				t.Fatalf("PurgeDeleted() = %d, %v, want 1, nil", purged, err)
+			}
+
+			_, err = users.Restore(ctx, deleted.ID, "admin")
+			assertError(t, err, domain.ErrUserNotFound)
+
+			if events, _ := loginEvents.ListRecentByUser(ctx, deleted.ID, 10); len(events) != 0 {
+				t.Errorf("login events of the purged user = %d, want 0", len(events))
+			}
+			if events, _ := loginEvents.ListRecentByUser(ctx, kept.ID, 10); len(events) != 1 {
+				t.Errorf("login events of the kept user = %d, want 1", len(events))
+			}
+
+			entry := audit.entries[len(audit.entries)-1]
+			if entry.Action != domain.AuditUsersPurged || entry.Details["login_events_deleted"] != int64(1) {
+				t.Errorf("last audit entry = %+v, want a purge deleting 1 login event", entry)
+			}
+		})
+	}
+}
@@ -2,121 +2,274 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"future-star-center-backend/internal/clock"
 	"future-star-center-backend/internal/config"
 	"future-star-center-backend/internal/handler"
+	"future-star-center-backend/internal/lifecycle"
+	"future-star-center-backend/internal/logger"
+	"future-star-center-backend/internal/mailer"
+	"future-star-center-backend/internal/metrics"
 	"future-star-center-backend/internal/middleware"
 	"future-star-center-backend/internal/repository"
 	"future-star-center-backend/internal/service"
-	"log"
+	"future-star-center-backend/internal/tracing"
+	"future-star-center-backend/pkg/utils"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
-	"github.com/redis/go-redis/v9"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
+// healthCheckTimeout bounds each dependency ping of the readiness probe
+const healthCheckTimeout = 2 * time.Second
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets masked and exit")
+	flag.Parse()
+
+	// Load configuration, printing it first when asked so that an invalid
+	// configuration can be inspected
+	cfg, err := config.Load(*configFile)
+	if *printConfig && cfg != nil {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal(slog.Default(), "failed to print configuration", err)
+		}
+	}
+	if err != nil {
+		// Printed as is, the aggregated errors are one per line
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		return
+	}
+
+	// Initialize logger
+	log := logger.New(os.Stdout, cfg.Env, cfg.LogLevel)
+	slog.SetDefault(log)
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, log, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize lifecycle manager
+	app := lifecycle.New(cfg.ShutdownTimeout, log)
+
+	// Initialize metrics
+	appMetrics := metrics.New()
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.Env)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal(log, "failed to set up tracing", err)
 	}
+	app.OnClose("tracing", shutdownTracing)
 
 	// Connect to MongoDB
-	mongoClient, err := connectMongoDB(cfg.MongoDB.URI)
+	mongoClient, err := connectMongoDB(cfg.MongoDB.URI, appMetrics, log)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatal(log, "failed to connect to MongoDB", err)
 	}
-	defer mongoClient.Disconnect(context.Background())
+	app.OnClose("mongodb", mongoClient.Disconnect)
 
 	// Connect to Redis
-	redisClient := connectRedis(cfg.Redis)
+	redisClient, err := connectRedis(context.Background(), cfg.Redis, log)
+	if err != nil {
+		fatal(log, "failed to connect to Redis", err)
+	}
+	app.OnClose("redis", func(context.Context) error { return redisClient.Close() })
+	appMetrics.InstrumentRedis(redisClient)
+	// Commands carry session IDs, OIDC verifiers and cached users, so spans
+	// must not record them
+	if err := redisotel.InstrumentTracing(redisClient, redisotel.WithDBStatement(false)); err != nil {
+		fatal(log, "failed to instrument Redis tracing", err)
+	}
+
+	// Open GeoIP database
+	geoLocator, err := utils.NewGeoLocator(cfg.LoginRisk.GeoIPDatabasePath)
+	if err != nil {
+		fatal(log, "failed to open GeoIP database", err)
+	}
+	app.OnClose("geoip", func(context.Context) error { return geoLocator.Close() })
 
 	// Initialize database
 	mongoDB := mongoClient.Database(cfg.MongoDB.Database)
 
-	// Create indexes
-	if err := createIndexes(mongoDB); err != nil {
-		log.Fatalf("Failed to create indexes: %v", err)
+	// Apply pending migrations
+	if cfg.MongoDB.MigrateOnStart {
+		if err := migrateUp(mongoDB, log); err != nil {
+			fatal(log, "failed to migrate database", err)
+		}
 	}
 
 	// Initialize repositories
-	userRepo := repository.NewMongoUserRepository(mongoDB)
-	sessionRepo := repository.NewRedisSessionRepository(redisClient)
+	systemClock := clock.Real()
+	userRepo := repository.NewMongoUserRepository(mongoDB, systemClock)
+	switch cfg.UserCache.Store {
+	case "memory":
+		cache := repository.NewLRUUserCache(cfg.UserCache.Size, cfg.UserCache.TTL, systemClock)
+		userRepo = repository.NewCachedUserRepository(userRepo, cache, log, appMetrics)
+	case "redis":
+		cache := repository.NewRedisUserCache(redisClient, cfg.UserCache.TTL)
+		userRepo = repository.NewCachedUserRepository(userRepo, cache, log, appMetrics)
+	}
+	var sessionRepo repository.SessionRepository
+	switch cfg.Session.Store {
+	case "mongo":
+		sessionRepo = repository.NewMongoSessionRepository(mongoDB, systemClock)
+	default:
+		sessionRepo = repository.NewRedisSessionRepository(redisClient, log, systemClock)
+	}
+	appMetrics.RegisterActiveSessions(sessionRepo.Count)
+	oidcStateRepo := repository.NewRedisOIDCStateRepository(redisClient)
+	apiKeyRepo := repository.NewMongoAPIKeyRepository(mongoDB, systemClock)
+	loginEventRepo := repository.NewMongoLoginEventRepository(mongoDB, systemClock)
+	loginChallengeRepo := repository.NewRedisLoginChallengeRepository(redisClient, systemClock)
+	networkPolicyRepo := repository.NewMongoNetworkPolicyRepository(mongoDB, systemClock)
+	auditRepo := repository.NewMongoAuditRepository(mongoDB, systemClock)
+
+	// Initialize mailer
+	mail := mailer.New(cfg.SMTP, log)
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, sessionRepo, cfg)
+	loginRiskService := service.NewLoginRiskService(loginEventRepo, geoLocator, mail, cfg, systemClock)
+	authService := service.NewAuthService(userRepo, sessionRepo, loginChallengeRepo, loginRiskService, mail, cfg, log, appMetrics, systemClock)
+	oidcService := service.NewOIDCService(userRepo, sessionRepo, oidcStateRepo, cfg, log, appMetrics, systemClock)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, log, systemClock)
+	networkPolicyService := service.NewNetworkPolicyService(networkPolicyRepo, auditRepo, cfg, log, systemClock)
+	userService := service.NewUserService(userRepo, sessionRepo, loginEventRepo, auditRepo, cfg, log, systemClock)
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(authService)
+	authHandler := handler.NewAuthHandler(authService, cfg.Session, log)
+	oidcHandler := handler.NewOIDCHandler(oidcService, cfg.Session, cfg.OIDC, log)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, log)
+	networkPolicyHandler := handler.NewNetworkPolicyHandler(networkPolicyService, log)
+	userHandler := handler.NewUserHandler(userService, log)
+	healthHandler := handler.NewHealthHandler(map[string]handler.HealthCheck{
+		"mongodb": func(ctx context.Context) error { return mongoClient.Ping(ctx, nil) },
+		"redis":   func(ctx context.Context) error { return redisClient.Ping(ctx).Err() },
+	}, healthCheckTimeout, log)
 
 	// Initialize Echo
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = handler.NewHTTPErrorHandler(log)
+	ipExtractor, err := middleware.NewIPExtractor(cfg.Network.TrustedProxies)
+	if err != nil {
+		fatal(log, "invalid trusted proxies", err)
+	}
+	e.IPExtractor = ipExtractor
 
 	// Middleware
-	e.Use(echomiddleware.Logger())
+	e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
+	e.Use(middleware.RequestIDMiddleware())
+	// Metrics read the status the request logger's error handling wrote
+	e.Use(middleware.MetricsMiddleware(appMetrics))
+	e.Use(middleware.RequestLoggerMiddleware(log))
 	e.Use(echomiddleware.Recover())
-	e.Use(echomiddleware.CORS())
-
-	// Health check endpoint
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{
-			"status":    "healthy",
-			"timestamp": time.Now().Format(time.RFC3339),
-		})
-	})
-
-	// API routes
-	api := e.Group("/api")
+	// Browser clients need the ETag of versioned records for If-Match and
+	// the CSRF token returned on login
+	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
+		ExposeHeaders: []string{handler.HeaderETag, middleware.CSRFHeaderName},
+	}))
+
+	authenticate := middleware.AuthMiddleware(authService, apiKeyService, cfg.Session)
+	networkPolicy := middleware.NetworkPolicyMiddleware(networkPolicyService)
+	registerRoutes(e, routeHandlers{
+		auth:          authHandler,
+		oidc:          oidcHandler,
+		apiKey:        apiKeyHandler,
+		networkPolicy: networkPolicyHandler,
+		user:          userHandler,
+		health:        healthHandler,
+		docs:          handler.NewDocsHandler(openAPISpec()),
+	}, authenticate, networkPolicy, appMetrics)
+
+	// Metrics are served on their own port, kept off the public network
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", appMetrics.Handler())
+	metricsServer := &http.Server{
+		Addr:              ":" + cfg.MetricsPort,
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	// Auth routes
-	auth := api.Group("/auth")
-	auth.POST("/register", authHandler.Register)
-	auth.POST("/login", authHandler.Login)
-	auth.POST("/request-password-reset", authHandler.RequestPasswordReset)
-	auth.POST("/reset-password", authHandler.ResetPassword)
+	// Stop taking traffic before draining in-flight requests, and keep
+	// serving until load balancers have seen readiness fail
+	app.OnStop("readiness", func(context.Context) error {
+		healthHandler.ShutDown()
+		return nil
+	})
+	app.OnStop("drain delay", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cfg.ShutdownDrainDelay):
+			return nil
+		}
+	})
+	app.OnStop("http server", e.Shutdown)
+	app.OnStop("metrics server", metricsServer.Shutdown)
 
-	// Protected auth routes
-	authProtected := auth.Group("")
-	authProtected.Use(middleware.AuthMiddleware(authService))
-	authProtected.POST("/logout", authHandler.Logout)
-	authProtected.GET("/session", authHandler.GetSession)
+	// Anonymise users deleted longer than the retention period ago
+	app.Go("user purge", func(ctx context.Context) {
+		runUserPurge(ctx, userService, cfg.UserRetention.PurgeInterval, log)
+	})
 
 	// Start server
 	go func() {
 		if err := e.Start(":" + cfg.Port); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal(log, "failed to start server", err)
 		}
 	}()
 
-	log.Printf("Server started on port %s", cfg.Port)
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal(log, "failed to start metrics server", err)
+		}
+	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	log.Println("Shutting down server...")
+	log.Info("server started", "port", cfg.Port, "metrics_port", cfg.MetricsPort)
 
-	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := e.Shutdown(ctx); err != nil {
-		log.Fatal(err)
+	// Wait for SIGINT or SIGTERM to gracefully shut down
+	if err := app.Wait(); err != nil {
+		fatal(log, "shutdown did not complete cleanly", err)
 	}
-	log.Println("Server shutdown complete")
+	log.Info("shutdown complete")
+}
+
+// fatal logs an unrecoverable startup error and exits
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-func connectMongoDB(uri string) (*mongo.Client, error) {
+func connectMongoDB(uri string, appMetrics *metrics.Metrics, log *slog.Logger) (*mongo.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	clientOptions := options.Client().
+		ApplyURI(uri).
+		SetMonitor(tracing.CombineCommandMonitors(otelmongo.NewMonitor(), appMetrics.MongoCommandMonitor())).
+		SetPoolMonitor(appMetrics.MongoPoolMonitor())
+
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -127,47 +280,6 @@ func connectMongoDB(uri string) (*mongo.Client, error) {
 		return nil, err
 	}
 
-	log.Println("Connected to MongoDB")
+	log.Info("connected to MongoDB")
 	return client, nil
 }
-
-func connectRedis(cfg config.RedisConfig) *redis.Client {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
-
-	// Test the connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := client.Ping(ctx).Result()
-	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
-	}
-
-	log.Println("Connected to Redis")
-	return client
-}
-
-func createIndexes(db *mongo.Database) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	users := db.Collection("users")
-
-	// Create unique index on email
-	indexModel := mongo.IndexModel{
-		Keys:    map[string]int{"email": 1},
-		Options: options.Index().SetUnique(true),
-	}
-
-	_, err := users.Indexes().CreateOne(ctx, indexModel)
-	if err != nil {
-		return fmt.Errorf("failed to create email index: %w", err)
-	}
-
-	log.Println("Database indexes created successfully")
-	return nil
-}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocation represents the approximate location of an IP address
+type GeoLocation struct {
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+// GeoLocator resolves IP addresses to locations
+type GeoLocator interface {
+	Locate(ip string) (*GeoLocation, error)
+	Close() error
+}
+
+// NewGeoLocator opens an offline MaxMind City database. An empty path returns
+// a locator that never resolves, disabling location based checks.
+func NewGeoLocator(path string) (GeoLocator, error) {
+	if path == "" {
+		return noopGeoLocator{}, nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &maxMindGeoLocator{reader: reader}, nil
+}
+
+type maxMindGeoLocator struct {
+	reader *geoip2.Reader
+}
+
+func (l *maxMindGeoLocator) Locate(ip string) (*GeoLocation, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, errors.New("invalid IP address")
+	}
+
+	record, err := l.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Private and unknown addresses have no coordinates
+	if record.Location.Latitude == 0 && record.Location.Longitude == 0 {
+		return nil, errors.New("location unknown")
+	}
+
+	return &GeoLocation{
+		Country:   record.Country.IsoCode,
+		City:      record.City.Names["en"],
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+	}, nil
+}
+
+func (l *maxMindGeoLocator) Close() error {
+	return l.reader.Close()
+}
+
+type noopGeoLocator struct{}
+
+func (noopGeoLocator) Locate(ip string) (*GeoLocation, error) {
+	return nil, errors.New("geolocation disabled")
+}
+
+func (noopGeoLocator) Close() error {
+	return nil
+}
+
+// DistanceKm returns the great-circle distance between two coordinates
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// IPRange returns the network an IP address belongs to (/24 for IPv4, /48
+// for IPv6), so that addresses handed out by the same ISP compare equal
+func IPRange(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
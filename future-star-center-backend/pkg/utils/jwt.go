@@ -16,16 +16,16 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT token for a user
-func GenerateJWT(user *domain.User, secret string, expiresIn time.Duration) (string, error) {
+// GenerateJWT generates a JWT token for a user, issued at now
+func GenerateJWT(user *domain.User, secret string, now time.Time, expiresIn time.Duration) (string, error) {
 	claims := JWTClaims{
 		UserID: user.ID.Hex(),
 		Email:  user.Email,
 		Role:   user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "future-star-center",
 			Subject:   user.ID.Hex(),
 		},
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"future-star-center-backend/internal/service"
+	"log/slog"
+	"time"
+)
+
+// runUserPurge purges deleted users past the retention period at startup and
+// then every interval until ctx is cancelled. Every replica runs it; a purge
+// only touches users that have not been purged yet, so overlapping runs are
+// harmless.
+func runUserPurge(ctx context.Context, users service.UserService, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := users.PurgeDeleted(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to purge deleted users", "error", err)
+		case purged > 0:
+			log.Info("purged deleted users", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"future-star-center-backend/internal/config"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisPingTimeout bounds each connection attempt at startup
+const redisPingTimeout = 5 * time.Second
+
+// connectRedis creates a Redis client for the configured mode and waits for
+// Redis to answer, retrying with exponential backoff so that the server
+// survives Redis starting after it
+func connectRedis(ctx context.Context, cfg config.RedisConfig, log *slog.Logger) (redis.UniversalClient, error) {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, redisPingTimeout)
+		err = client.Ping(pingCtx).Err()
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt == cfg.ConnectAttempts {
+			client.Close()
+			return nil, fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+
+		log.Warn("failed to connect to Redis, retrying",
+			"attempt", attempt,
+			"retry_in", backoff,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			client.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, cfg.ConnectMaxBackoff)
+	}
+
+	log.Info("connected to Redis", "mode", cfg.Mode)
+	return client, nil
+}
+
+// newRedisClient creates a client for a standalone server, a Sentinel
+// monitored master or a cluster
+func newRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := redisTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+		TLSConfig:        tlsConfig,
+	}
+
+	// The mode is chosen explicitly: redis.NewUniversalClient would take a
+	// cluster with a single seed node for a standalone server
+	switch cfg.Mode {
+	case "sentinel":
+		return redis.NewFailoverClient(options.Failover()), nil
+	case "cluster":
+		return redis.NewClusterClient(options.Cluster()), nil
+	default:
+		return redis.NewClient(options.Simple()), nil
+	}
+}
+
+// redisTLSConfig builds the TLS configuration of Redis connections, or nil
+// when TLS is disabled
+func redisTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("Redis CA file holds no PEM certificates")
+		}
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
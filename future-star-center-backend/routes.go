@@ -0,0 +1,128 @@
+package main
+
+import (
+	"future-star-center-backend/internal/domain"
+	"future-star-center-backend/internal/handler"
+	"future-star-center-backend/internal/metrics"
+	"future-star-center-backend/internal/middleware"
+	"future-star-center-backend/internal/openapi"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiVersion is a mounted version of the API and the function registering
+// its routes
+type apiVersion struct {
+	middleware.APIVersion
+	register func(g *echo.Group, h routeHandlers, authenticate, networkPolicy echo.MiddlewareFunc)
+}
+
+// apiVersions lists the mounted versions of the API. A breaking change ships
+// as a new version, e.g. /api/v2 with its own register function, mounted next
+// to the existing ones; the version it replaces is then deprecated with a
+// sunset date.
+var apiVersions = []apiVersion{
+	{
+		APIVersion: middleware.APIVersion{Name: "v1", Prefix: "/api/v1"},
+		register:   registerV1,
+	},
+	{
+		// The unversioned routes predate versioning and stay for installed apps
+		APIVersion: middleware.APIVersion{
+			Name:         "legacy",
+			Prefix:       "/api",
+			DeprecatedAt: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+			Sunset:       time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+			Successor:    "/api/v1",
+		},
+		register: registerV1,
+	},
+}
+
+// routeHandlers holds the handlers serving the API routes
+type routeHandlers struct {
+	auth          *handler.AuthHandler
+	oidc          *handler.OIDCHandler
+	apiKey        *handler.APIKeyHandler
+	networkPolicy *handler.NetworkPolicyHandler
+	user          *handler.UserHandler
+	health        *handler.HealthHandler
+	docs          *handler.DocsHandler
+}
+
+// registerRoutes registers every route of the API. Routes added here must be
+// documented in handler.OpenAPISpec as well.
+func registerRoutes(e *echo.Echo, h routeHandlers, authenticate, networkPolicy echo.MiddlewareFunc, m *metrics.Metrics) {
+	// Health check endpoints
+	e.GET("/livez", h.health.Livez)
+	e.GET("/readyz", h.health.Readyz)
+	e.GET("/health", h.health.Readyz)
+
+	// API documentation
+	e.GET("/api/openapi.json", h.docs.Spec)
+	e.GET("/api/docs", h.docs.UI)
+
+	for _, version := range apiVersions {
+		g := e.Group(version.Prefix, middleware.APIVersionMiddleware(version.APIVersion, m))
+		version.register(g, h, authenticate, networkPolicy)
+	}
+}
+
+// openAPISpec builds the OpenAPI document of the mounted API versions
+func openAPISpec() *openapi.Document {
+	versions := make([]handler.SpecVersion, 0, len(apiVersions))
+	for _, version := range apiVersions {
+		versions = append(versions, handler.SpecVersion{
+			Name:       version.Name,
+			Prefix:     version.Prefix,
+			Deprecated: version.Deprecated(),
+		})
+	}
+	return handler.OpenAPISpec(versions...)
+}
+
+// registerV1 registers the routes of version 1 of the API
+func registerV1(api *echo.Group, h routeHandlers, authenticate, networkPolicy echo.MiddlewareFunc) {
+	// Auth routes
+	auth := api.Group("/auth")
+	auth.POST("/register", h.auth.Register)
+	auth.POST("/login", h.auth.Login)
+	auth.POST("/login/verify", h.auth.VerifyLogin)
+	auth.POST("/request-password-reset", h.auth.RequestPasswordReset)
+	auth.POST("/reset-password", h.auth.ResetPassword)
+
+	// OpenID Connect federation routes
+	oidc := auth.Group("/oidc")
+	oidc.GET("/providers", h.oidc.Providers)
+	oidc.GET("/:provider/authorize", h.oidc.Authorize)
+	oidc.GET("/:provider/callback", h.oidc.Callback)
+
+	// Protected auth routes
+	authProtected := auth.Group("")
+	authProtected.Use(authenticate)
+	authProtected.Use(networkPolicy)
+	authProtected.Use(middleware.CSRFMiddleware())
+	authProtected.POST("/logout", h.auth.Logout)
+	authProtected.GET("/session", h.auth.GetSession)
+
+	// Admin routes. Routes machine clients need also accept API keys holding
+	// the route's scope.
+	adminOnly := middleware.RoleMiddleware(string(domain.RoleAdmin))
+	adminOrScope := func(scopes ...domain.APIKeyScope) echo.MiddlewareFunc {
+		return middleware.RoleOrScopeMiddleware([]string{string(domain.RoleAdmin)}, scopes...)
+	}
+	admin := api.Group("/admin")
+	admin.Use(authenticate)
+	admin.Use(networkPolicy)
+	admin.Use(middleware.CSRFMiddleware())
+	admin.POST("/api-keys", h.apiKey.Create, adminOnly)
+	admin.GET("/api-keys", h.apiKey.List, adminOnly)
+	admin.DELETE("/api-keys/:id", h.apiKey.Revoke, adminOnly)
+	admin.GET("/network-policies", h.networkPolicy.List, adminOnly)
+	admin.PUT("/network-policies/:role", h.networkPolicy.Update, adminOnly)
+	admin.GET("/users/:id", h.user.Get, adminOrScope(domain.ScopeUsersRead))
+	admin.PATCH("/users/:id", h.user.Update, adminOnly)
+	admin.DELETE("/users/:id", h.user.Delete, adminOnly)
+	admin.POST("/users/:id/restore", h.user.Restore, adminOnly)
+}
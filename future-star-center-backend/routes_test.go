@@ -0,0 +1,112 @@
+package main
+
+import (
+	"future-star-center-backend/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+var echoParam = regexp.MustCompile(`:(\w+)`)
+
+// TestRoutesMatchOpenAPISpec fails when a route is registered without being
+// documented in the OpenAPI document, or documented without being registered
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	passThrough := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+
+	e := echo.New()
+	registerRoutes(e, routeHandlers{}, passThrough, passThrough, nil)
+
+	registered := map[openapi.Route]bool{}
+	for _, r := range e.Routes() {
+		// Groups with middleware add catch-all routes for unmatched paths
+		if r.Method == echo.RouteNotFound {
+			continue
+		}
+		registered[openapi.Route{Method: r.Method, Path: echoParam.ReplaceAllString(r.Path, "{$1}")}] = true
+	}
+
+	documented := map[openapi.Route]bool{}
+	for _, r := range openAPISpec().Routes() {
+		documented[r] = true
+	}
+
+	for _, r := range sortedRoutes(registered) {
+		if !documented[r] {
+			t.Errorf("%s %s is registered but missing from the OpenAPI document", r.Method, r.Path)
+		}
+	}
+	for _, r := range sortedRoutes(documented) {
+		if !registered[r] {
+			t.Errorf("%s %s is documented but not registered", r.Method, r.Path)
+		}
+	}
+}
+
+// TestRoutesAnnounceDeprecation checks the deprecation headers of each
+// mounted version, on a route that is rejected before reaching a handler
+func TestRoutesAnnounceDeprecation(t *testing.T) {
+	reject := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error { return echo.ErrUnauthorized }
+	}
+	passThrough := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+
+	e := echo.New()
+	registerRoutes(e, routeHandlers{}, reject, passThrough, nil)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantHeaders map[string]string
+	}{
+		{
+			name: "unversioned routes are deprecated",
+			path: "/api/auth/session",
+			wantHeaders: map[string]string{
+				"Deprecation": "@1792281600",
+				"Sunset":      "Fri, 30 Apr 2027 00:00:00 GMT",
+				"Link":        `</api/v1>; rel="successor-version"`,
+			},
+		},
+		{
+			name: "v1 routes are current",
+			path: "/api/v1/auth/session",
+			wantHeaders: map[string]string{
+				"Deprecation": "",
+				"Sunset":      "",
+				"Link":        "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func sortedRoutes(routes map[openapi.Route]bool) []openapi.Route {
+	sorted := make([]openapi.Route, 0, len(routes))
+	for r := range routes {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path+" "+sorted[i].Method < sorted[j].Path+" "+sorted[j].Method
+	})
+	return sorted
+}
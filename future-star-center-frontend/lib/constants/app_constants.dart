@@ -3,7 +3,7 @@ import 'package:flutter/material.dart';
 class AppConstants {
   // API Configuration
   static const String baseUrl = 'http://10.42.0.1:8080';
-  static const String apiVersion = '/api';
+  static const String apiVersion = '/api/v1';
   static const String authEndpoint = '$apiVersion/auth';
 
   // API Endpoints